@@ -0,0 +1,139 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type ChurnOptions struct {
+	Rate          string
+	Mode          string
+	Duration      time.Duration
+	SettleTimeout time.Duration
+}
+
+type ChurnTableOutput struct {
+	Namespace   string `table:"namespace"`
+	Name        string `table:"name"`
+	Mode        string `table:"mode,wide"`
+	Disruptions string `table:"disruptions"`
+	Replaced    string `table:"replaced"`
+	Ready       string `table:"ready"`
+	MovedNodes  string `table:"moved nodes"`
+	LatencyP50  string `table:"p50"`
+	LatencyP90  string `table:"p90"`
+	LatencyMax  string `table:"max"`
+}
+
+var (
+	churnModes   = []string{inflater.ChurnModeDelete, inflater.ChurnModeRollout}
+	churnOptions = &ChurnOptions{}
+	cmdChurn     = &cobra.Command{
+		Use:   "churn <name>",
+		Short: "continuously replace an inflatable's pods and measure how fast they come back",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			interval, err := parseRate(churnOptions.Rate)
+			if err != nil {
+				return err
+			}
+			if !lo.Contains(churnModes, churnOptions.Mode) {
+				return inflater.NewValidationError("--mode must be one of %v, got %q", churnModes, churnOptions.Mode)
+			}
+			if churnOptions.Duration <= 0 {
+				return inflater.NewValidationError("--duration must be positive, got %s", churnOptions.Duration)
+			}
+			if churnOptions.SettleTimeout < 0 {
+				return inflater.NewValidationError("--settle-timeout must not be negative, got %s", churnOptions.SettleTimeout)
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			clientset, err := kubeClientset("")
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(clientset).WithLogger(logger)
+			fmt.Fprintf(os.Stderr, "Churning %s/%s every %s for %s\n", globalOpts.Namespace, args[0], interval, churnOptions.Duration)
+			result, err := inflate.Churn(ctx, inflater.ChurnOptions{
+				Namespace:     globalOpts.Namespace,
+				Name:          args[0],
+				Mode:          churnOptions.Mode,
+				Interval:      interval,
+				Duration:      churnOptions.Duration,
+				SettleTimeout: churnOptions.SettleTimeout,
+			})
+			switch globalOpts.Output {
+			case OutputYAML:
+				fmt.Println(PrettyEncode(result))
+			case OutputTableShort, OutputTableWide:
+				fmt.Println(PrettyTable([]ChurnTableOutput{{
+					Namespace:   result.Namespace,
+					Name:        result.Name,
+					Mode:        result.Mode,
+					Disruptions: fmt.Sprint(result.Disruptions),
+					Replaced:    fmt.Sprintf("%d/%d", result.Replacements, result.DeletedPods),
+					Ready:       fmt.Sprintf("%d/%d", result.Ready, result.Replacements),
+					MovedNodes:  fmt.Sprintf("%d/%d", result.MovedNodes, result.Ready),
+					LatencyP50:  result.LatencyP50.Round(time.Millisecond).String(),
+					LatencyP90:  result.LatencyP90.Round(time.Millisecond).String(),
+					LatencyMax:  result.LatencyMax.Round(time.Millisecond).String(),
+				}}, globalOpts.Output == OutputTableWide))
+			default:
+				return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+			}
+			return err
+		},
+	}
+)
+
+// minChurnInterval is the shortest interval between disruptions parseRate allows
+const minChurnInterval = time.Millisecond
+
+// parseRate parses a rate like 10/min into the interval between events
+func parseRate(rate string) (time.Duration, error) {
+	units := map[string]time.Duration{"s": time.Second, "sec": time.Second, "m": time.Minute, "min": time.Minute, "h": time.Hour, "hour": time.Hour}
+	countStr, unitStr, found := strings.Cut(rate, "/")
+	count, err := strconv.ParseFloat(countStr, 64)
+	unit, ok := units[unitStr]
+	if !found || err != nil || !ok || count <= 0 || math.IsInf(count, 0) || math.IsNaN(count) {
+		return 0, inflater.NewValidationError("--rate must be a count per s, min or h, e.g. 10/min, got %q", rate)
+	}
+	interval := time.Duration(float64(unit) / count)
+	if interval < minChurnInterval {
+		return 0, inflater.NewValidationError("--rate must be at most one per %s, got %q", minChurnInterval, rate)
+	}
+	return interval, nil
+}
+
+func init() {
+	cmdChurn.Flags().StringVar(&churnOptions.Rate, "rate", "10/min", "how often to disrupt the pods, e.g. 10/min")
+	cmdChurn.Flags().StringVar(&churnOptions.Mode, "mode", inflater.ChurnModeDelete,
+		fmt.Sprintf("how to disrupt the pods, delete a random pod or roll the deployment: %v", churnModes))
+	cmdChurn.Flags().DurationVar(&churnOptions.Duration, "duration", 5*time.Minute, "how long to keep churning")
+	cmdChurn.Flags().DurationVar(&churnOptions.SettleTimeout, "settle-timeout", 2*time.Minute, "how long to wait for the last replacements to become ready")
+	rootCmd.AddCommand(cmdChurn)
+}
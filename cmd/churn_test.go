@@ -0,0 +1,52 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for _, tc := range []struct {
+		rate    string
+		want    time.Duration
+		wantErr bool
+	}{
+		{rate: "10/min", want: 6 * time.Second},
+		{rate: "1/s", want: time.Second},
+		{rate: "2/sec", want: 500 * time.Millisecond},
+		{rate: "0.5/m", want: 2 * time.Minute},
+		{rate: "60/hour", want: time.Minute},
+		{rate: "1000/s", want: time.Millisecond},
+		{rate: "1001/s", wantErr: true},
+		{rate: "1e9/h", wantErr: true},
+		{rate: "Inf/min", wantErr: true},
+		{rate: "NaN/min", wantErr: true},
+		{rate: "0/min", wantErr: true},
+		{rate: "-1/min", wantErr: true},
+		{rate: "10/day", wantErr: true},
+		{rate: "10", wantErr: true},
+		{rate: "ten/min", wantErr: true},
+	} {
+		t.Run(tc.rate, func(t *testing.T) {
+			got, err := parseRate(tc.rate)
+			assertValidationError(t, err, tc.wantErr)
+			if got != tc.want {
+				t.Errorf("parseRate(%q) = %s, want %s", tc.rate, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+// ContextOptions select the kubeconfig contexts a command fans out to
+type ContextOptions struct {
+	Contexts    []string
+	AllContexts bool
+}
+
+// Cluster is the inflater of a single kubeconfig context, Context is empty unless fanning out
+type Cluster struct {
+	Context   string
+	Clientset *kubernetes.Clientset
+	Inflater  *inflater.Inflater
+	// Err is why the clientset of the context could not be built, forEachCluster reports it as the cluster's error
+	Err error
+}
+
+var contextOptions = &ContextOptions{}
+
+// AddContextFlags registers the flags to run a command against several contexts
+func AddContextFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVar(&contextOptions.Contexts, "contexts", nil, "run against each of these kubeconfig contexts in parallel, e.g. a,b,c")
+	cmd.Flags().BoolVar(&contextOptions.AllContexts, "all-contexts", false, "run against every kubeconfig context in parallel")
+}
+
+// FanOut is true when more than the current context was requested
+func (o ContextOptions) FanOut() bool {
+	return len(o.Contexts) > 0 || o.AllContexts
+}
+
+// kubeClusters returns a cluster per requested context, or only the current context when not fanning out.
+// A context whose clientset cannot be built is returned with its Err so that the other contexts still run.
+func kubeClusters() ([]Cluster, error) {
+	if !contextOptions.FanOut() {
+		clientset, err := kubeClientset("")
+		if err != nil {
+			return nil, err
+		}
+		return []Cluster{{Clientset: clientset, Inflater: inflater.New(clientset).WithLogger(logger)}}, nil
+	}
+	contexts := lo.Uniq(contextOptions.Contexts)
+	if contextOptions.AllContexts {
+		rawConfig, err := kubeClientConfig("").RawConfig()
+		if err != nil {
+			return nil, err
+		}
+		contexts = lo.Keys(rawConfig.Contexts)
+		sort.Strings(contexts)
+	}
+	if len(contexts) == 0 {
+		return nil, inflater.NewValidationError("no kubeconfig contexts found")
+	}
+	var clusters []Cluster
+	for _, context := range contexts {
+		clientset, err := kubeClientset(context)
+		if err != nil {
+			clusters = append(clusters, Cluster{Context: context, Err: err})
+			continue
+		}
+		clusters = append(clusters, Cluster{Context: context, Clientset: clientset, Inflater: inflater.New(clientset).WithLogger(contextLogger(context))})
+	}
+	return clusters, nil
+}
+
+// forEachCluster runs fn against the clusters in parallel. The results are in the order of the clusters
+// and the errors are aggregated, prefixed with their context, rather than stopping the other clusters.
+func forEachCluster[T any](clusters []Cluster, fn func(cluster Cluster) (T, error)) ([]T, error) {
+	results := make([]T, len(clusters))
+	errs := make([]error, len(clusters))
+	var wg sync.WaitGroup
+	for idx, cluster := range clusters {
+		wg.Add(1)
+		go func(idx int, cluster Cluster) {
+			defer wg.Done()
+			if cluster.Err != nil {
+				errs[idx] = cluster.Err
+			} else {
+				results[idx], errs[idx] = fn(cluster)
+			}
+			if errs[idx] != nil && cluster.Context != "" {
+				errs[idx] = fmt.Errorf("cluster %s: %w", cluster.Context, errs[idx])
+			}
+		}(idx, cluster)
+	}
+	wg.Wait()
+	return results, multierr.Combine(errs...)
+}
+
+// clusterErrors returns a PartialError when only some of the clusters failed and the aggregated errors when all of them did
+func clusterErrors(clusters []Cluster, err error) error {
+	errs := multierr.Errors(err)
+	if len(errs) > 0 && len(errs) < len(clusters) {
+		return &inflater.PartialError{Errs: errs}
+	}
+	return err
+}
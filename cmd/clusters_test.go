@@ -0,0 +1,38 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+func TestForEachClusterSkipsClustersWithoutClientset(t *testing.T) {
+	clusters := []Cluster{{Context: "a", Err: errors.New("invalid configuration")}, {Context: "b"}}
+	results, err := forEachCluster(clusters, func(cluster Cluster) (string, error) { return cluster.Context, nil })
+	if want := []string{"", "b"}; !reflect.DeepEqual(results, want) {
+		t.Errorf("forEachCluster() = %q, want %q", results, want)
+	}
+	if err == nil || err.Error() != "cluster a: invalid configuration" {
+		t.Errorf("forEachCluster() error = %v, want the error of cluster a", err)
+	}
+	var partialErr *inflater.PartialError
+	if !errors.As(clusterErrors(clusters, err), &partialErr) {
+		t.Errorf("clusterErrors() = %v, want a PartialError", clusterErrors(clusters, err))
+	}
+}
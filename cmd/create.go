@@ -15,85 +15,443 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
-	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 
 	"github.com/bwagner5/inflate/pkg/inflater"
+	"github.com/bwagner5/inflate/pkg/report"
 )
 
 type CreateOptions struct {
-	DryRun             bool
-	RandomSuffix       bool
-	Image              string
-	ZonalSpread        bool
-	HostnameSpread     bool
-	CapacityTypeSpread bool
-	HostNetwork        bool
-	CPUArch            string
-	OS                 string
-	Service            bool
+	Name string
+	// Namespace overrides the --namespace flag for an inflate in a config file
+	Namespace           string
+	DryRun              bool
+	RandomSuffix        bool
+	Image               string
+	ZonalSpread         bool
+	HostnameSpread      bool
+	CapacityTypeSpread  bool
+	AntiAffinity        string
+	Tolerations         []string
+	HostNetwork         bool
+	CPUArch             string
+	OS                  string
+	NodePool            string
+	CapacityType        string
+	InstanceType        string
+	InstanceFamily      string
+	DoNotDisrupt        bool
+	Service             bool
+	PriorityClass       string
+	CreatePriorityClass int32
+	PreemptionPolicy    string
+	ForceConflicts      bool
+	Protect             bool
+	HPA                 string
+	Load                string
+	LoadImage           string
+	Stress              string
+	StressImage         string
+	ReportOptions       `yaml:",inline"`
+}
+
+// CreateTableOutput is an apply result when creating in several clusters
+type CreateTableOutput struct {
+	Cluster   string `table:"cluster"`
+	Kind      string `table:"kind"`
+	Name      string `table:"name"`
+	Operation string `table:"operation"`
+	Changes   string `table:"changes,wide"`
 }
 
 var (
-	createOptions = &CreateOptions{}
-	cmdCreate     = &cobra.Command{
+	capacityTypes      = []string{"spot", "on-demand"}
+	preemptionPolicies = []string{string(corev1.PreemptLowerPriority), string(corev1.PreemptNever)}
+	taintEffects       = []string{string(corev1.TaintEffectNoSchedule), string(corev1.TaintEffectPreferNoSchedule), string(corev1.TaintEffectNoExecute)}
+	createOptions      = &CreateOptions{}
+	cmdCreate          = &cobra.Command{
 		Use:   "create",
 		Short: "create an inflatable or maybe a few",
 		Args:  cobra.MinimumNArgs(0),
-		Run: func(cmd *cobra.Command, args []string) {
+		RunE: func(cmd *cobra.Command, args []string) error {
+			optionsList, err := createOptions.InflaterOptions(cmd)
+			if err != nil {
+				return err
+			}
+			if createOptions.DryRun && createOptions.Recording() {
+				return inflater.NewValidationError("--report and --node-timeline cannot be used with --dry-run")
+			}
+			if contextOptions.FanOut() && !createOptions.DryRun {
+				if createOptions.Recording() {
+					return inflater.NewValidationError("--report and --node-timeline cannot be used with --contexts or --all-contexts")
+				}
+				return createInClusters(cmd.Context(), optionsList)
+			}
 			var clientset *kubernetes.Clientset
 			if !createOptions.DryRun {
-				clientset = kubeClientset()
-			}
-			inflate := inflater.New(clientset)
-			options := inflater.Options{
-				RandomSuffix:       createOptions.RandomSuffix,
-				Namespace:          globalOpts.Namespace,
-				Image:              createOptions.Image,
-				ZonalSpread:        createOptions.ZonalSpread,
-				HostnameSpread:     createOptions.HostnameSpread,
-				CapacityTypeSpread: createOptions.CapacityTypeSpread,
-				HostNetwork:        createOptions.HostNetwork,
-				CPUArch:            createOptions.CPUArch,
-				OS:                 createOptions.OS,
-				Service:            createOptions.Service,
-				DryRun:             createOptions.DryRun,
+				if clientset, err = kubeClientset(""); err != nil {
+					return err
+				}
 			}
-			inflateCollection, err := inflate.Inflate(cmd.Context(), options)
+			recorder, err := startRecorder(cmd.Context(), clientset, createOptions.ReportOptions,
+				lo.Map(optionsList, func(options inflater.Options, _ int) string { return options.Namespace }))
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				return err
 			}
-			// Output
-			if createOptions.DryRun || globalOpts.Output == OutputYAML {
-				fmt.Println(PrettyEncode(inflateCollection.Deployment))
-				if createOptions.Service {
-					fmt.Println("---")
-					fmt.Println(PrettyEncode(inflateCollection.Service))
+			inflate := inflater.New(clientset).WithLogger(logger)
+			var resources []report.Resource
+			desiredReplicas := map[report.Resource]int32{}
+			for idx, options := range optionsList {
+				inflateCollection, err := inflate.Inflate(cmd.Context(), options)
+				if err != nil {
+					return fmt.Errorf("creating inflate %s, %w", options.Name, err)
+				}
+				resources = append(resources, report.ResourcesOf(inflateCollection.Objects()...)...)
+				if !options.DryRun {
+					desiredReplicas[report.ResourcesOf(inflateCollection.Deployment)[0]] = lo.FromPtrOr(inflateCollection.Deployment.Spec.Replicas, 1)
 				}
-			} else {
-				fmt.Printf("Created Deployment %s/%s\n", inflateCollection.Deployment.GetNamespace(), inflateCollection.Deployment.GetName())
-				if createOptions.Service {
-					fmt.Printf("Created Service %s/%s\n", inflateCollection.Service.GetNamespace(), inflateCollection.Service.GetName())
+				// Output
+				if options.DryRun || globalOpts.Output == OutputYAML {
+					if idx > 0 {
+						fmt.Println("---")
+					}
+					for objIdx, obj := range inflateCollection.Objects() {
+						if objIdx > 0 {
+							fmt.Println("---")
+						}
+						fmt.Println(PrettyEncode(obj))
+					}
+				} else {
+					for _, result := range inflateCollection.Results {
+						fmt.Println(FormatApplyResult(result))
+					}
 				}
 			}
+			return writeReport(cmd.Context(), recorder, createOptions.ReportOptions, "create", optionsList, resources, func(pods []report.PodTimeline) bool {
+				for deployment, replicas := range desiredReplicas {
+					ready := lo.CountBy(podsOf(pods, deployment), func(pod report.PodTimeline) bool { return pod.Ready != nil && pod.Deleted == nil })
+					if ready < int(replicas) {
+						return false
+					}
+				}
+				return true
+			})
 		},
 	}
 )
 
+// createInClusters applies the inflates to every cluster in parallel and prints one table of the results
+func createInClusters(ctx context.Context, optionsList []inflater.Options) error {
+	clusters, err := kubeClusters()
+	if err != nil {
+		return err
+	}
+	clusterCollections, err := forEachCluster(clusters, func(cluster Cluster) ([]*inflater.InflateCollection, error) {
+		var collections []*inflater.InflateCollection
+		for _, options := range optionsList {
+			inflateCollection, err := cluster.Inflater.Inflate(ctx, options)
+			if inflateCollection != nil {
+				collections = append(collections, inflateCollection)
+			}
+			if err != nil {
+				return collections, err
+			}
+		}
+		return collections, nil
+	})
+	switch globalOpts.Output {
+	case OutputYAML:
+		objectsByContext := map[string][]runtime.Object{}
+		for idx, collections := range clusterCollections {
+			for _, inflateCollection := range collections {
+				objectsByContext[clusters[idx].Context] = append(objectsByContext[clusters[idx].Context], inflateCollection.Objects()...)
+			}
+		}
+		fmt.Println(PrettyEncode(objectsByContext))
+	default:
+		var rows []CreateTableOutput
+		for idx, collections := range clusterCollections {
+			for _, inflateCollection := range collections {
+				for _, result := range inflateCollection.Results {
+					rows = append(rows, CreateTableOutput{
+						Cluster:   clusters[idx].Context,
+						Kind:      result.Kind,
+						Name:      lo.Ternary(result.Namespace == "", result.Name, fmt.Sprintf("%s/%s", result.Namespace, result.Name)),
+						Operation: result.Operation,
+						Changes: strings.Join(lo.Map(result.Changes, func(change inflater.FieldChange, _ int) string {
+							return fmt.Sprintf("%s: %s -> %s", change.Path, change.Before, change.After)
+						}), "; "),
+					})
+				}
+			}
+		}
+		fmt.Println(PrettyTable(rows, globalOpts.Output == OutputTableWide))
+	}
+	return clusterErrors(clusters, err)
+}
+
+// FormatApplyResult describes an apply result, listing the changed fields of re-applied objects
+func FormatApplyResult(result inflater.ApplyResult) string {
+	name := lo.Ternary(result.Namespace == "", result.Name, fmt.Sprintf("%s/%s", result.Namespace, result.Name))
+	var out strings.Builder
+	switch result.Operation {
+	case inflater.OperationCreated:
+		out.WriteString(fmt.Sprintf("Created %s %s", result.Kind, name))
+	case inflater.OperationConfigured:
+		out.WriteString(fmt.Sprintf("Updated %s %s", result.Kind, name))
+	default:
+		out.WriteString(fmt.Sprintf("Unchanged %s %s", result.Kind, name))
+	}
+	for _, change := range result.Changes {
+		out.WriteString(fmt.Sprintf("\n  ~ %s: %s -> %s", change.Path, change.Before, change.After))
+	}
+	return out.String()
+}
+
+// InflaterOptions merges each document of the config file with the flags into the options for the inflater
+func (o CreateOptions) InflaterOptions(cmd *cobra.Command) ([]inflater.Options, error) {
+	configs, err := ParseConfigs(globalOpts, o)
+	if err != nil {
+		return nil, &inflater.ValidationError{Err: fmt.Errorf("parsing %s, %w", globalOpts.ConfigFile, err)}
+	}
+	var optionsList []inflater.Options
+	for _, opts := range configs {
+		if opts.PreemptionPolicy != "" && !cmd.Flag("create-priority-class").Changed && opts.CreatePriorityClass == 0 {
+			return nil, inflater.NewValidationError("--preemption-policy requires --create-priority-class")
+		}
+		if opts.PreemptionPolicy != "" && !lo.Contains(preemptionPolicies, opts.PreemptionPolicy) {
+			return nil, inflater.NewValidationError("--preemption-policy must be one of %v, got %q", preemptionPolicies, opts.PreemptionPolicy)
+		}
+		if opts.CapacityType != "" && !lo.Contains(capacityTypes, opts.CapacityType) {
+			return nil, inflater.NewValidationError("--capacity-type must be one of %v, got %q", capacityTypes, opts.CapacityType)
+		}
+		hpa, err := parseHPA(opts.HPA)
+		if err != nil {
+			return nil, err
+		}
+		loadCPU, err := parseLoad(opts.Load)
+		if err != nil {
+			return nil, err
+		}
+		stress, err := parseStress(opts.Stress)
+		if err != nil {
+			return nil, err
+		}
+		tolerations, err := parseTolerations(opts.Tolerations)
+		if err != nil {
+			return nil, err
+		}
+		if loadCPU != nil && stress != nil {
+			return nil, inflater.NewValidationError("--load and --stress cannot be used together")
+		}
+		options := inflater.Options{
+			Name:               opts.Name,
+			RandomSuffix:       opts.RandomSuffix,
+			Namespace:          lo.Ternary(opts.Namespace != "", opts.Namespace, globalOpts.Namespace),
+			Image:              opts.Image,
+			ZonalSpread:        opts.ZonalSpread,
+			HostnameSpread:     opts.HostnameSpread,
+			CapacityTypeSpread: opts.CapacityTypeSpread,
+			AntiAffinity:       opts.AntiAffinity,
+			Tolerations:        tolerations,
+			HostNetwork:        opts.HostNetwork,
+			CPUArch:            opts.CPUArch,
+			OS:                 opts.OS,
+			NodePool:           opts.NodePool,
+			CapacityType:       opts.CapacityType,
+			InstanceType:       opts.InstanceType,
+			InstanceFamily:     opts.InstanceFamily,
+			DoNotDisrupt:       opts.DoNotDisrupt,
+			Service:            opts.Service,
+			DryRun:             opts.DryRun,
+			PriorityClassName:  opts.PriorityClass,
+			PreemptionPolicy:   opts.PreemptionPolicy,
+			ForceConflicts:     opts.ForceConflicts,
+			Protect:            opts.Protect,
+			HPA:                hpa,
+			LoadCPU:            loadCPU,
+			LoadImage:          opts.LoadImage,
+			Stress:             stress,
+			StressImage:        opts.StressImage,
+		}
+		if cmd.Flag("create-priority-class").Changed || opts.CreatePriorityClass != 0 {
+			options.PriorityClassValue = lo.ToPtr(opts.CreatePriorityClass)
+		}
+		optionsList = append(optionsList, options)
+	}
+	return optionsList, nil
+}
+
+// parseHPA parses min:max:targetCPU%, e.g. 1:10:50%
+func parseHPA(hpa string) (*inflater.HPAOptions, error) {
+	if hpa == "" {
+		return nil, nil
+	}
+	parts := strings.Split(hpa, ":")
+	if len(parts) != 3 {
+		return nil, inflater.NewValidationError("--hpa must be min:max:targetCPU%%, got %q", hpa)
+	}
+	var values []int32
+	for _, part := range []string{parts[0], parts[1], strings.TrimSuffix(parts[2], "%")} {
+		value, err := strconv.ParseInt(part, 10, 32)
+		if err != nil || value < 1 {
+			return nil, inflater.NewValidationError("--hpa must be min:max:targetCPU%% with positive integers, got %q", hpa)
+		}
+		values = append(values, int32(value))
+	}
+	if values[1] < values[0] {
+		return nil, inflater.NewValidationError("--hpa max %d must not be less than min %d", values[1], values[0])
+	}
+	return &inflater.HPAOptions{MinReplicas: values[0], MaxReplicas: values[1], TargetCPUUtilization: values[2]}, nil
+}
+
+// parseLoad parses the cpu the busy loop should use, e.g. cpu=500m
+func parseLoad(load string) (*resource.Quantity, error) {
+	if load == "" {
+		return nil, nil
+	}
+	values, err := parseKeyValues(load, "cpu")
+	if err != nil {
+		return nil, inflater.NewValidationError("--load %w", err)
+	}
+	cpu, err := resource.ParseQuantity(values["cpu"])
+	if err != nil || cpu.Sign() <= 0 {
+		return nil, inflater.NewValidationError("--load cpu must be a positive quantity, got %q", values["cpu"])
+	}
+	return &cpu, nil
+}
+
+// parseStress parses the stress container options, e.g. cpu=2,memory=256Mi,duration=10m,ramp=1m,leak=true,limit=512Mi
+func parseStress(stress string) (*inflater.StressOptions, error) {
+	if stress == "" {
+		return nil, nil
+	}
+	values, err := parseKeyValues(stress, "cpu", "memory", "duration", "ramp", "leak", "limit")
+	if err != nil {
+		return nil, inflater.NewValidationError("--stress %w", err)
+	}
+	options := &inflater.StressOptions{}
+	if cpu, ok := values["cpu"]; ok {
+		if options.CPU, err = strconv.Atoi(cpu); err != nil || options.CPU < 0 {
+			return nil, inflater.NewValidationError("--stress cpu must be a number of processes, got %q", cpu)
+		}
+	}
+	for key, quantity := range map[string]**resource.Quantity{"memory": &options.Memory, "limit": &options.MemoryLimit} {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		parsed, err := resource.ParseQuantity(value)
+		if err != nil || parsed.Sign() <= 0 {
+			return nil, inflater.NewValidationError("--stress %s must be a positive quantity, got %q", key, value)
+		}
+		*quantity = &parsed
+	}
+	for key, duration := range map[string]*time.Duration{"duration": &options.Duration, "ramp": &options.RampUp} {
+		value, ok := values[key]
+		if !ok {
+			continue
+		}
+		if *duration, err = time.ParseDuration(value); err != nil || *duration < 0 {
+			return nil, inflater.NewValidationError("--stress %s must be a duration, got %q", key, value)
+		}
+	}
+	if leak, ok := values["leak"]; ok {
+		if options.Leak, err = strconv.ParseBool(leak); err != nil {
+			return nil, inflater.NewValidationError("--stress leak must be true or false, got %q", leak)
+		}
+	}
+	if options.Leak && options.Memory == nil {
+		return nil, inflater.NewValidationError("--stress leak requires memory")
+	}
+	return options, nil
+}
+
+// parseTolerations parses key[=value][:effect] tolerations, e.g. dedicated=batch:NoSchedule.
+// A toleration without a value tolerates any value of the key and one without an effect tolerates every effect.
+func parseTolerations(tolerations []string) ([]corev1.Toleration, error) {
+	var parsed []corev1.Toleration
+	for _, toleration := range tolerations {
+		keyValue, effect, _ := strings.Cut(toleration, ":")
+		key, value, hasValue := strings.Cut(keyValue, "=")
+		if key == "" || (hasValue && value == "") {
+			return nil, inflater.NewValidationError("--tolerations must be key[=value][:effect], got %q", toleration)
+		}
+		if effect != "" && !lo.Contains(taintEffects, effect) {
+			return nil, inflater.NewValidationError("--tolerations effect must be one of %v, got %q", taintEffects, effect)
+		}
+		parsed = append(parsed, corev1.Toleration{
+			Key:      key,
+			Operator: lo.Ternary(hasValue, corev1.TolerationOpEqual, corev1.TolerationOpExists),
+			Value:    value,
+			Effect:   corev1.TaintEffect(effect),
+		})
+	}
+	return parsed, nil
+}
+
+// parseKeyValues parses comma separated key=value pairs, allowing only the given keys
+func parseKeyValues(keyValues string, keys ...string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, pair := range strings.Split(keyValues, ",") {
+		key, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found || value == "" {
+			return nil, fmt.Errorf("must be comma separated key=value pairs, got %q", pair)
+		}
+		if !lo.Contains(keys, key) {
+			return nil, fmt.Errorf("key must be one of %v, got %q", keys, key)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// AddCreateFlags registers the flags describing an inflate, shared by create and the commands that render one
+func AddCreateFlags(cmd *cobra.Command, opts *CreateOptions) {
+	cmd.Flags().StringVar(&opts.Name, "name", "inflate", "name of the deployment")
+	cmd.Flags().StringVarP(&opts.Image, "image", "i", inflater.DefaultImage, "Container image to use")
+	cmd.Flags().BoolVarP(&opts.ZonalSpread, "zonal-spread", "z", false, "add a zonal topology spread constraint")
+	cmd.Flags().BoolVar(&opts.HostnameSpread, "hostname-spread", false, "add a hostname topology spread constraint")
+	cmd.Flags().BoolVar(&opts.CapacityTypeSpread, "capacity-type-spread", false, "add a capacity-type topology spread constraint")
+	cmd.Flags().StringVar(&opts.AntiAffinity, "anti-affinity", "", "add a required pod anti-affinity on this topology key, e.g. kubernetes.io/hostname")
+	cmd.Flags().StringSliceVar(&opts.Tolerations, "tolerations", nil, fmt.Sprintf("tolerate taints as key[=value][:effect] with an effect of %v, e.g. dedicated=batch:NoSchedule", taintEffects))
+	cmd.Flags().BoolVar(&opts.HostNetwork, "host-network", false, "use host networking")
+	cmd.Flags().StringVarP(&opts.CPUArch, "cpu-arch", "c", "", "CPU Architecture to use for nodeSelector")
+	cmd.Flags().StringVar(&opts.OS, "os", "", "Operating System to use for nodeSelector")
+	cmd.Flags().StringVar(&opts.NodePool, "nodepool", "", "Karpenter NodePool to use for nodeSelector")
+	cmd.Flags().StringVar(&opts.CapacityType, "capacity-type", "", fmt.Sprintf("Capacity type to use for nodeSelector: %v", capacityTypes))
+	cmd.Flags().StringVar(&opts.InstanceType, "instance-type", "", "Instance type to use for nodeSelector")
+	cmd.Flags().StringVar(&opts.InstanceFamily, "instance-family", "", "Instance family to use for nodeSelector")
+	cmd.Flags().BoolVar(&opts.DoNotDisrupt, "do-not-disrupt", false, "annotate the pods so Karpenter does not voluntarily disrupt their nodes")
+	cmd.Flags().BoolVar(&opts.RandomSuffix, "random-suffix", false, "add a random suffix to the deployment name")
+	cmd.Flags().BoolVar(&opts.Service, "service", true, "Create a K8s service")
+	cmd.Flags().StringVar(&opts.PriorityClass, "priority-class", "", "PriorityClass name to set on the pods")
+	cmd.Flags().Int32Var(&opts.CreatePriorityClass, "create-priority-class", 0, "create a managed PriorityClass with this value (named after the deployment unless --priority-class is set)")
+	cmd.Flags().StringVar(&opts.PreemptionPolicy, "preemption-policy", "", fmt.Sprintf("Preemption policy of the created PriorityClass: %v", preemptionPolicies))
+	cmd.Flags().StringVar(&opts.HPA, "hpa", "", "create an autoscaling/v2 HorizontalPodAutoscaler as min:max:targetCPU%, e.g. 1:10:50%")
+	cmd.Flags().StringVar(&opts.Load, "load", "", "swap the container for a busy loop using this much CPU, e.g. cpu=500m")
+	cmd.Flags().StringVar(&opts.LoadImage, "load-image", inflater.DefaultLoadImage, "image with sh, seq and nproc to run the --load busy loop")
+	cmd.Flags().StringVar(&opts.Stress, "stress", "", "swap the container for a stress process, e.g. cpu=2,memory=256Mi,duration=10m,ramp=1m,leak=true,limit=512Mi")
+	cmd.Flags().StringVar(&opts.StressImage, "stress-image", inflater.DefaultStressImage, "python3 image to run the --stress process")
+	cmd.Flags().BoolVar(&opts.ForceConflicts, "force-conflicts", false, "take ownership of fields managed by other controllers when re-applying")
+	cmd.Flags().BoolVar(&opts.Protect, "protect", false, fmt.Sprintf("annotate the deployment with %s so delete skips it unless --force is given", inflater.AnnotationProtect))
+}
+
 func init() {
-	cmdCreate.Flags().StringVarP(&createOptions.Image, "image", "i", "public.ecr.aws/eks-distro/kubernetes/pause:3.7", "Container image to use")
-	cmdCreate.Flags().BoolVarP(&createOptions.ZonalSpread, "zonal-spread", "z", false, "add a zonal topology spread constraint")
-	cmdCreate.Flags().BoolVar(&createOptions.HostnameSpread, "hostname-spread", false, "add a hostname topology spread constraint")
-	cmdCreate.Flags().BoolVar(&createOptions.CapacityTypeSpread, "capacity-type-spread", false, "add a capacity-type topology spread constraint")
-	cmdCreate.Flags().BoolVar(&createOptions.HostNetwork, "host-network", false, "use host networking")
-	cmdCreate.Flags().StringVarP(&createOptions.CPUArch, "cpu-arch", "c", "", "CPU Architecture to use for nodeSelector")
-	cmdCreate.Flags().StringVar(&createOptions.OS, "os", "", "Operating System to use for nodeSelector")
-	cmdCreate.Flags().BoolVar(&createOptions.RandomSuffix, "random-suffix", false, "add a random suffix to the deployment name")
-	cmdCreate.Flags().BoolVar(&createOptions.Service, "service", true, "Create a K8s service")
+	AddCreateFlags(cmdCreate, createOptions)
 	cmdCreate.Flags().BoolVar(&createOptions.DryRun, "dry-run", false, "Dry-run prints the K8s manifests without applying")
+	AddReportFlags(cmdCreate, &createOptions.ReportOptions, "all pods are ready")
+	AddContextFlags(cmdCreate)
 	rootCmd.AddCommand(cmdCreate)
 }
@@ -0,0 +1,198 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+// assertValidationError fails unless err is a ValidationError when wantErr is set and nil otherwise
+func assertValidationError(t *testing.T, err error, wantErr bool) {
+	t.Helper()
+	var validationErr *inflater.ValidationError
+	switch {
+	case wantErr && !errors.As(err, &validationErr):
+		t.Fatalf("error = %v, want a ValidationError", err)
+	case !wantErr && err != nil:
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestParseHPA(t *testing.T) {
+	for _, tc := range []struct {
+		hpa     string
+		want    *inflater.HPAOptions
+		wantErr bool
+	}{
+		{hpa: ""},
+		{hpa: "1:10:50%", want: &inflater.HPAOptions{MinReplicas: 1, MaxReplicas: 10, TargetCPUUtilization: 50}},
+		{hpa: "2:2:80", want: &inflater.HPAOptions{MinReplicas: 2, MaxReplicas: 2, TargetCPUUtilization: 80}},
+		{hpa: "1:10", wantErr: true},
+		{hpa: "1:10:50%:1", wantErr: true},
+		{hpa: "0:10:50%", wantErr: true},
+		{hpa: "1:10:0%", wantErr: true},
+		{hpa: "a:10:50%", wantErr: true},
+		{hpa: "1:3000000000:50%", wantErr: true},
+		{hpa: "5:2:50%", wantErr: true},
+	} {
+		t.Run(tc.hpa, func(t *testing.T) {
+			got, err := parseHPA(tc.hpa)
+			assertValidationError(t, err, tc.wantErr)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseHPA(%q) = %+v, want %+v", tc.hpa, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseLoad(t *testing.T) {
+	for _, tc := range []struct {
+		load    string
+		want    string
+		wantErr bool
+	}{
+		{load: ""},
+		{load: "cpu=500m", want: "500m"},
+		{load: " cpu=2", want: "2"},
+		{load: "cpu=0", wantErr: true},
+		{load: "cpu=-1", wantErr: true},
+		{load: "cpu=lots", wantErr: true},
+		{load: "memory=1Gi", wantErr: true},
+		{load: "cpu", wantErr: true},
+	} {
+		t.Run(tc.load, func(t *testing.T) {
+			got, err := parseLoad(tc.load)
+			assertValidationError(t, err, tc.wantErr)
+			switch {
+			case tc.want == "" && got != nil:
+				t.Errorf("parseLoad(%q) = %s, want none", tc.load, got)
+			case tc.want != "" && (got == nil || got.Cmp(resource.MustParse(tc.want)) != 0):
+				t.Errorf("parseLoad(%q) = %v, want %s", tc.load, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseKeyValues(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		keyValues string
+		want      map[string]string
+		wantErr   bool
+	}{
+		{name: "single", keyValues: "cpu=1", want: map[string]string{"cpu": "1"}},
+		{name: "spaces around pairs", keyValues: "cpu=1, memory=1Gi", want: map[string]string{"cpu": "1", "memory": "1Gi"}},
+		{name: "last value wins", keyValues: "cpu=1,cpu=2", want: map[string]string{"cpu": "2"}},
+		{name: "unknown key", keyValues: "cpu=1,disk=1Gi", wantErr: true},
+		{name: "missing value", keyValues: "cpu=", wantErr: true},
+		{name: "missing equals", keyValues: "cpu", wantErr: true},
+		{name: "trailing comma", keyValues: "cpu=1,", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseKeyValues(tc.keyValues, "cpu", "memory")
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseKeyValues(%q) error = %v, want error %t", tc.keyValues, err, tc.wantErr)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseKeyValues(%q) = %v, want %v", tc.keyValues, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseTolerations(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		tolerations []string
+		want        []corev1.Toleration
+		wantErr     bool
+	}{
+		{name: "none"},
+		{
+			name:        "key value and effect",
+			tolerations: []string{"dedicated=batch:NoSchedule"},
+			want:        []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "batch", Effect: corev1.TaintEffectNoSchedule}},
+		},
+		{
+			name:        "any value and effect",
+			tolerations: []string{"gpu:NoExecute", "spot"},
+			want: []corev1.Toleration{
+				{Key: "gpu", Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+				{Key: "spot", Operator: corev1.TolerationOpExists},
+			},
+		},
+		{name: "missing key", tolerations: []string{"=batch:NoSchedule"}, wantErr: true},
+		{name: "missing value", tolerations: []string{"dedicated=:NoSchedule"}, wantErr: true},
+		{name: "unknown effect", tolerations: []string{"dedicated=batch:Never"}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseTolerations(tc.tolerations)
+			assertValidationError(t, err, tc.wantErr)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseTolerations(%q) = %+v, want %+v", tc.tolerations, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseStress(t *testing.T) {
+	for _, tc := range []struct {
+		stress  string
+		want    *inflater.StressOptions
+		wantErr bool
+	}{
+		{stress: ""},
+		{stress: "cpu=2", want: &inflater.StressOptions{CPU: 2}},
+		{
+			stress: "cpu=2,memory=256Mi,duration=10m,ramp=1m,leak=true,limit=512Mi",
+			want: &inflater.StressOptions{
+				CPU:         2,
+				Memory:      lo.ToPtr(resource.MustParse("256Mi")),
+				MemoryLimit: lo.ToPtr(resource.MustParse("512Mi")),
+				Duration:    10 * time.Minute,
+				RampUp:      time.Minute,
+				Leak:        true,
+			},
+		},
+		{stress: "memory=1E", want: &inflater.StressOptions{Memory: lo.ToPtr(resource.MustParse("1E"))}},
+		{stress: "cpu=-1", wantErr: true},
+		{stress: "cpu=two", wantErr: true},
+		{stress: "memory=0", wantErr: true},
+		{stress: "memory=lots", wantErr: true},
+		{stress: "limit=-1Gi", wantErr: true},
+		{stress: "duration=10", wantErr: true},
+		{stress: "ramp=-1m", wantErr: true},
+		{stress: "memory=1Gi,leak=maybe", wantErr: true},
+		{stress: "leak=true", wantErr: true},
+		{stress: "disk=1Gi", wantErr: true},
+	} {
+		t.Run(tc.stress, func(t *testing.T) {
+			got, err := parseStress(tc.stress)
+			assertValidationError(t, err, tc.wantErr)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseStress(%q) = %+v, want %+v", tc.stress, got, tc.want)
+			}
+		})
+	}
+}
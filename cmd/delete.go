@@ -15,16 +15,38 @@ limitations under the License.
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	appsv1 "k8s.io/api/apps/v1"
 
 	"github.com/bwagner5/inflate/pkg/inflater"
+	"github.com/bwagner5/inflate/pkg/report"
 )
 
 type DeleteOptions struct {
-	All bool
+	All            bool
+	IgnoreNotFound bool
+	Yes            bool
+	Force          bool
+	DryRun         bool
+	ReportOptions
+}
+
+// DeleteTableOutput is the result of deleting a single object
+type DeleteTableOutput struct {
+	Cluster string `table:"cluster,omitempty"`
+	Kind    string `table:"kind"`
+	Name    string `table:"name"`
+	Result  string `table:"result"`
+	Error   string `table:"error,wide,omitempty"`
 }
 
 var (
@@ -32,33 +54,184 @@ var (
 	cmdDelete     = &cobra.Command{
 		Use:   "delete [name]",
 		Short: "delete an inflatable or maybe a few",
-		Args:  cobra.MinimumNArgs(0),
-		Run: func(cmd *cobra.Command, args []string) {
+		Long: `delete an inflatable or maybe a few
+
+The objects that will be deleted are listed and have to be confirmed unless --yes is given,
+--dry-run only lists them. Inflates created with --protect are skipped unless --force is given.`,
+		Args: cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
 			if !rootCmd.Flag("namespace").Changed && len(args) == 0 && !deleteOptions.All {
-				fmt.Println("must specify --namespace OR name OR --all")
-				os.Exit(1)
+				return inflater.NewValidationError("must specify --namespace OR name OR --all")
 			}
-			clientset := kubeClientset()
-			inflate := inflater.New(clientset)
-			deleteFilters := inflater.DeleteFilters{}
+			deleteOpts := inflater.DeleteOptions{Force: deleteOptions.Force}
 			if rootCmd.Flag("namespace").Changed {
-				deleteFilters.Namespace = globalOpts.Namespace
+				deleteOpts.Filters.Namespace = globalOpts.Namespace
 			}
 			if len(args) > 0 {
-				deleteFilters.Name = args[0]
+				deleteOpts.Filters.Name = args[0]
+			}
+			if contextOptions.FanOut() && deleteOptions.Recording() {
+				return inflater.NewValidationError("--report and --node-timeline cannot be used with --contexts or --all-contexts")
+			}
+			clusters, err := kubeClusters()
+			if err != nil {
+				return err
+			}
+			// the objects of each context that were confirmed, nil with --yes to delete whatever matches
+			var confirmed map[string][]inflater.DeleteResult
+			if deleteOptions.DryRun || !deleteOptions.Yes {
+				if confirmed, err = confirmDelete(cmd.Context(), clusters, deleteOpts); confirmed == nil || err != nil {
+					return err
+				}
+			}
+			if contextOptions.FanOut() {
+				return deleteInClusters(cmd.Context(), clusters, deleteOpts, confirmed)
+			}
+			inflate := clusters[0].Inflater
+			if confirmed != nil {
+				deleteOpts.Objects = confirmed[clusters[0].Context]
+			}
+
+			var resources []report.Resource
+			if deleteOptions.Recording() {
+				deployments, err := inflate.List(cmd.Context(), inflater.ListFilters(deleteOpts.Filters))
+				if err != nil {
+					return fmt.Errorf("listing inflates, %w", err)
+				}
+				resources = lo.Map(deployments, func(deployment appsv1.Deployment, _ int) report.Resource {
+					return report.Resource{Kind: "Deployment", Namespace: deployment.Namespace, Name: deployment.Name, UID: deployment.UID}
+				})
+			}
+			recorder, err := startRecorder(cmd.Context(), clusters[0].Clientset, deleteOptions.ReportOptions,
+				lo.Map(resources, func(resource report.Resource, _ int) string { return resource.Namespace }))
+			if err != nil {
+				return err
 			}
 
-			err := inflate.Delete(cmd.Context(), deleteFilters)
+			results, err := inflate.Delete(cmd.Context(), deleteOpts)
+			err = ignoreNotFound(err)
+			if printErr := printDeleteResults(os.Stdout, clusters, [][]inflater.DeleteResult{results}, err); printErr != nil {
+				return printErr
+			}
 			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+				return fmt.Errorf("deleting inflates, %w", err)
 			}
-			fmt.Println("Successfully Deleted Inflates")
+			return writeReport(cmd.Context(), recorder, deleteOptions.ReportOptions, "delete", deleteOpts.Filters, resources, func(pods []report.PodTimeline) bool {
+				return lo.EveryBy(resources, func(deployment report.Resource) bool {
+					return lo.EveryBy(podsOf(pods, deployment), func(pod report.PodTimeline) bool { return pod.Deleted != nil })
+				})
+			})
 		},
 	}
 )
 
+// confirmDelete prints what a dry run would delete and asks to go ahead. It returns the objects to delete in each
+// context, keyed by the context, only when the delete should proceed. Unless the dry run is all there is to print,
+// the plan and the prompt go to stderr so that stdout only has the results of the delete.
+func confirmDelete(ctx context.Context, clusters []Cluster, deleteOpts inflater.DeleteOptions) (map[string][]inflater.DeleteResult, error) {
+	deleteOpts.DryRun = true
+	plans, err := forEachCluster(clusters, func(cluster Cluster) ([]inflater.DeleteResult, error) {
+		results, err := cluster.Inflater.Delete(ctx, deleteOpts)
+		return results, ignoreNotFound(err)
+	})
+	count := lo.SumBy(plans, func(results []inflater.DeleteResult) int {
+		return lo.CountBy(results, func(result inflater.DeleteResult) bool { return result.Operation == inflater.OperationWouldDelete })
+	})
+	out := io.Writer(os.Stderr)
+	if deleteOptions.DryRun || count == 0 || err != nil {
+		out = os.Stdout
+	}
+	if printErr := printDeleteResults(out, clusters, plans, err); printErr != nil {
+		return nil, printErr
+	}
+	if err != nil {
+		return nil, fmt.Errorf("planning the delete, %w", clusterErrors(clusters, err))
+	}
+	if deleteOptions.DryRun || count == 0 {
+		return nil, nil
+	}
+	fmt.Fprintf(os.Stderr, "Delete %d objects? [y/N] ", count)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer := strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return nil, errors.New("delete was not confirmed, pass --yes to skip the confirmation")
+	}
+	return lo.SliceToMap(lo.Range(len(clusters)), func(idx int) (string, []inflater.DeleteResult) {
+		return clusters[idx].Context, lo.Filter(plans[idx], func(result inflater.DeleteResult, _ int) bool {
+			return result.Operation == inflater.OperationWouldDelete
+		})
+	}), nil
+}
+
+// deleteInClusters deletes the inflates from every cluster in parallel and prints one table of the results.
+// When confirmed is not nil only the confirmed objects of each context are deleted.
+func deleteInClusters(ctx context.Context, clusters []Cluster, deleteOpts inflater.DeleteOptions, confirmed map[string][]inflater.DeleteResult) error {
+	results, err := forEachCluster(clusters, func(cluster Cluster) ([]inflater.DeleteResult, error) {
+		opts := deleteOpts
+		if confirmed != nil {
+			opts.Objects = confirmed[cluster.Context]
+		}
+		results, err := cluster.Inflater.Delete(ctx, opts)
+		return results, ignoreNotFound(err)
+	})
+	if printErr := printDeleteResults(os.Stdout, clusters, results, err); printErr != nil {
+		return printErr
+	}
+	return clusterErrors(clusters, err)
+}
+
+// printDeleteResults prints the deleted objects of each cluster to out, err is only used to tell that nothing was deleted
+func printDeleteResults(out io.Writer, clusters []Cluster, clusterResults [][]inflater.DeleteResult, err error) error {
+	switch globalOpts.Output {
+	case OutputYAML:
+		if contextOptions.FanOut() {
+			fmt.Fprintln(out, PrettyEncode(lo.SliceToMap(lo.Range(len(clusters)), func(idx int) (string, []inflater.DeleteResult) {
+				return clusters[idx].Context, clusterResults[idx]
+			})))
+		} else {
+			fmt.Fprintln(out, PrettyEncode(clusterResults[0]))
+		}
+	case OutputTableShort, OutputTableWide:
+		var rows []DeleteTableOutput
+		for idx, results := range clusterResults {
+			rows = append(rows, lo.Map(results, func(result inflater.DeleteResult, _ int) DeleteTableOutput {
+				return DeleteTableOutput{
+					Cluster: clusters[idx].Context,
+					Kind:    result.Kind,
+					Name:    lo.Ternary(result.Namespace == "", result.Name, fmt.Sprintf("%s/%s", result.Namespace, result.Name)),
+					Result:  result.Operation,
+					Error:   result.Error,
+				}
+			})...)
+		}
+		if len(rows) == 0 {
+			if err == nil {
+				fmt.Fprintln(out, "No inflates found to delete")
+			}
+			return nil
+		}
+		fmt.Fprintln(out, PrettyTable(rows, globalOpts.Output == OutputTableWide))
+	default:
+		return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+	}
+	return nil
+}
+
+// ignoreNotFound drops the NotFoundError of deleting nothing unless --ignore-not-found=false
+func ignoreNotFound(err error) error {
+	var notFoundErr *inflater.NotFoundError
+	if deleteOptions.IgnoreNotFound && errors.As(err, &notFoundErr) {
+		return nil
+	}
+	return err
+}
+
 func init() {
 	cmdDelete.Flags().BoolVarP(&deleteOptions.All, "all", "a", false, "delete all inflates")
+	cmdDelete.Flags().BoolVar(&deleteOptions.IgnoreNotFound, "ignore-not-found", true, fmt.Sprintf("succeed when there is nothing to delete, otherwise exit %d", ExitNotFound))
+	cmdDelete.Flags().BoolVarP(&deleteOptions.Yes, "yes", "y", false, "delete without listing the objects and asking for confirmation")
+	cmdDelete.Flags().BoolVar(&deleteOptions.Force, "force", false, "also delete inflates created with --protect")
+	cmdDelete.Flags().BoolVar(&deleteOptions.DryRun, "dry-run", false, "only list the objects that would be deleted")
+	AddReportFlags(cmdDelete, &deleteOptions.ReportOptions, "all pods are deleted")
+	AddContextFlags(cmdDelete)
 	rootCmd.AddCommand(cmdDelete)
 }
@@ -0,0 +1,67 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+var (
+	diffOptions = &CreateOptions{}
+	cmdDiff     = &cobra.Command{
+		Use:   "diff",
+		Short: "diff an inflatable against the live cluster state",
+		Long:  "diff an inflatable against the live cluster state. Exits 1 when there are differences, see inflate --help for the exit codes of errors.",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			optionsList, err := diffOptions.InflaterOptions(cmd)
+			if err != nil {
+				return err
+			}
+			clientset, err := kubeClientset("")
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(clientset).WithLogger(logger)
+			changed := false
+			for _, options := range optionsList {
+				diffs, err := inflate.Diff(cmd.Context(), options)
+				if err != nil {
+					return fmt.Errorf("diffing inflate %s, %w", options.Name, err)
+				}
+				for _, objectDiff := range diffs {
+					if objectDiff.Diff == "" {
+						continue
+					}
+					changed = true
+					fmt.Print(objectDiff.Diff)
+				}
+			}
+			if changed {
+				return errDifferences
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	AddCreateFlags(cmdDiff, diffOptions)
+	rootCmd.AddCommand(cmdDiff)
+}
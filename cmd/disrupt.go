@@ -0,0 +1,111 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type DisruptOptions struct {
+	Nodes        int
+	Mode         string
+	Timeout      time.Duration
+	KeepCordoned bool
+}
+
+type DisruptTableOutput struct {
+	Mode             string `table:"mode"`
+	Nodes            string `table:"nodes"`
+	EvictedPods      string `table:"evicted pods"`
+	BlockedEvictions string `table:"blocked evictions,wide"`
+	TimeToReady      string `table:"time to ready"`
+	Uncordoned       string `table:"uncordoned,wide"`
+}
+
+var (
+	disruptModes   = []string{inflater.DisruptModeCordon, inflater.DisruptModeDrain, inflater.DisruptModeDelete}
+	disruptOptions = &DisruptOptions{}
+	cmdDisrupt     = &cobra.Command{
+		Use:   "disrupt [name]",
+		Short: "cordon, drain or delete nodes running inflatables and measure how fast they recover",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if !lo.Contains(disruptModes, disruptOptions.Mode) {
+				return inflater.NewValidationError("--mode must be one of %v, got %q", disruptModes, disruptOptions.Mode)
+			}
+			if disruptOptions.Nodes < 1 {
+				return inflater.NewValidationError("--nodes must be at least 1, got %d", disruptOptions.Nodes)
+			}
+			if disruptOptions.Timeout <= 0 {
+				return inflater.NewValidationError("--timeout must be positive, got %s", disruptOptions.Timeout)
+			}
+			listFilters := inflater.ListFilters{}
+			if rootCmd.Flag("namespace").Changed {
+				listFilters.Namespace = globalOpts.Namespace
+			}
+			if len(args) > 0 {
+				listFilters.Name = args[0]
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt)
+			defer stop()
+			clientset, err := kubeClientset("")
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(clientset).WithLogger(logger)
+			result, err := inflate.Disrupt(ctx, inflater.DisruptOptions{
+				Filters:      listFilters,
+				Nodes:        disruptOptions.Nodes,
+				Mode:         disruptOptions.Mode,
+				Timeout:      disruptOptions.Timeout,
+				KeepCordoned: disruptOptions.KeepCordoned,
+			})
+			switch globalOpts.Output {
+			case OutputYAML:
+				fmt.Println(PrettyEncode(result))
+			case OutputTableShort, OutputTableWide:
+				fmt.Println(PrettyTable([]DisruptTableOutput{{
+					Mode:             result.Mode,
+					Nodes:            strings.Join(result.Nodes, ","),
+					EvictedPods:      fmt.Sprint(result.EvictedPods),
+					BlockedEvictions: fmt.Sprint(result.BlockedEvictions),
+					TimeToReady:      lo.Ternary(result.TimedOut, "timed out", result.TimeToReady.Round(time.Second).String()),
+					Uncordoned:       strings.Join(result.Uncordoned, ","),
+				}}, globalOpts.Output == OutputTableWide))
+			default:
+				return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+			}
+			return err
+		},
+	}
+)
+
+func init() {
+	cmdDisrupt.Flags().IntVar(&disruptOptions.Nodes, "nodes", 1, "number of nodes running inflate pods to disrupt")
+	cmdDisrupt.Flags().StringVar(&disruptOptions.Mode, "mode", inflater.DisruptModeCordon,
+		fmt.Sprintf("cordon and evict the inflate pods, drain every pod, or drain and delete the nodes: %v", disruptModes))
+	cmdDisrupt.Flags().DurationVar(&disruptOptions.Timeout, "timeout", 10*time.Minute, "how long to wait for evictions and for the replicas to be ready again")
+	cmdDisrupt.Flags().BoolVar(&disruptOptions.KeepCordoned, "keep-cordoned", false, "leave the nodes cordoned instead of restoring them at the end")
+	rootCmd.AddCommand(cmdDisrupt)
+}
@@ -0,0 +1,115 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/go-logr/logr"
+	"github.com/spf13/cobra"
+	"go.uber.org/multierr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+// Exit codes, 1 is reserved for diff finding differences like diff(1)
+const (
+	ExitDifferences = 1
+	ExitError       = 2
+	ExitValidation  = 3
+	ExitNotFound    = 4
+	ExitPartial     = 5
+	ExitTimeout     = 6
+	ExitUnreachable = 7
+	// ExitUnschedulable is returned by simulate like ExitDifferences by diff, the outcome is already printed
+	ExitUnschedulable = 8
+)
+
+// exitCodesHelp documents the exit codes in the root command's help
+var exitCodesHelp = fmt.Sprintf(`Exit codes:
+  %d  diff found differences
+  %d  error
+  %d  invalid flags or options
+  %d  no inflate matched, e.g. nothing to delete
+  %d  partial failure, some namespaces, objects or clusters failed
+  %d  timed out
+  %d  the API server is unreachable
+  %d  simulate left replicas unschedulable`,
+	ExitDifferences, ExitError, ExitValidation, ExitNotFound, ExitPartial, ExitTimeout, ExitUnreachable, ExitUnschedulable)
+
+// errDifferences is returned by diff when the live state differs, diff already printed the differences
+var errDifferences = errors.New("differences found")
+
+// errUnschedulable is returned by simulate when replicas did not fit, simulate already printed why
+var errUnschedulable = errors.New("replicas are unschedulable")
+
+// exitCode maps the typed errors of the inflater and the client to the exit codes
+func exitCode(err error) int {
+	var validationErr *inflater.ValidationError
+	var partialErr *inflater.PartialError
+	var timeoutErr *inflater.TimeoutError
+	var notFoundErr *inflater.NotFoundError
+	var netErr net.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, errDifferences):
+		return ExitDifferences
+	case errors.Is(err, errUnschedulable):
+		return ExitUnschedulable
+	case errors.As(err, &validationErr):
+		return ExitValidation
+	case errors.As(err, &partialErr):
+		return ExitPartial
+	case errors.As(err, &timeoutErr), errors.Is(err, context.DeadlineExceeded), apierrors.IsTimeout(err), apierrors.IsServerTimeout(err):
+		return ExitTimeout
+	case errors.As(err, &notFoundErr), apierrors.IsNotFound(err):
+		return ExitNotFound
+	case errors.As(err, &netErr):
+		return ExitUnreachable
+	default:
+		return ExitError
+	}
+}
+
+// printError writes the error to stderr, each aggregated error on its own line, or logs it with --log-format json
+func printError(cmd *cobra.Command, err error) {
+	if errors.Is(err, errDifferences) || errors.Is(err, errUnschedulable) {
+		return
+	}
+	if globalOpts.LogFormat == LogFormatJSON {
+		errLogger := logger
+		if errLogger.GetSink() == logr.Discard().GetSink() {
+			// flag, argument and usage errors happen before PersistentPreRunE sets up the logger
+			errLogger, _ = newLogger(os.Stderr, LogFormatJSON, globalOpts.Verbose)
+		}
+		errLogger.Error(err, "command failed", "command", cmd.CommandPath(), "exitCode", exitCode(err))
+		return
+	}
+	errs := multierr.Errors(err)
+	var partialErr *inflater.PartialError
+	if errors.As(err, &partialErr) {
+		fmt.Fprintf(os.Stderr, "Error: %s partially failed\n", cmd.CommandPath())
+		errs = partialErr.Errs
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+	}
+}
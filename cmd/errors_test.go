@@ -0,0 +1,83 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/bwagner5/inflate/pkg/exporter"
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+func TestExitCode(t *testing.T) {
+	_, exportErr := exporter.Export("zip", t.TempDir(), nil)
+	for _, tc := range []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "success", err: nil, want: 0},
+		{name: "differences", err: errDifferences, want: ExitDifferences},
+		{name: "unschedulable", err: errUnschedulable, want: ExitUnschedulable},
+		{name: "validation", err: inflater.NewValidationError("--mode must be one of [a b]"), want: ExitValidation},
+		{name: "wrapped validation", err: fmt.Errorf("parsing, %w", inflater.NewValidationError("bad")), want: ExitValidation},
+		{name: "unknown export format", err: exportErr, want: ExitValidation},
+		{name: "partial", err: &inflater.PartialError{Errs: []error{errors.New("a")}}, want: ExitPartial},
+		{name: "timeout", err: &inflater.TimeoutError{Operation: "waiting", Timeout: time.Minute}, want: ExitTimeout},
+		{name: "deadline exceeded", err: fmt.Errorf("waiting, %w", context.DeadlineExceeded), want: ExitTimeout},
+		{name: "server timeout", err: apierrors.NewServerTimeout(schema.GroupResource{Resource: "pods"}, "list", 1), want: ExitTimeout},
+		{name: "not found", err: &inflater.NotFoundError{Namespace: "inflate", Name: "x"}, want: ExitNotFound},
+		{name: "api not found", err: apierrors.NewNotFound(schema.GroupResource{Group: "apps", Resource: "deployments"}, "x"), want: ExitNotFound},
+		{name: "unreachable", err: fmt.Errorf("listing, %w", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}), want: ExitUnreachable},
+		{name: "other", err: errors.New("boom"), want: ExitError},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitCode(tc.err); got != tc.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestValidateArgs(t *testing.T) {
+	root := &cobra.Command{Use: "root", Args: cobra.NoArgs, RunE: func(*cobra.Command, []string) error { return nil }}
+	sub := &cobra.Command{Use: "sub", Args: cobra.ExactArgs(1), RunE: func(*cobra.Command, []string) error { return nil }}
+	root.AddCommand(sub)
+	validateArgs(root)
+	for _, tc := range []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"bogus"}, want: ExitValidation},
+		{args: []string{"sub"}, want: ExitValidation},
+		{args: []string{"sub", "a", "b"}, want: ExitValidation},
+		{args: []string{"sub", "a"}, want: 0},
+	} {
+		root.SetArgs(tc.args)
+		root.SilenceErrors, root.SilenceUsage = true, true
+		if _, err := root.ExecuteC(); exitCode(err) != tc.want {
+			t.Errorf("executing %q exits %d (%v), want %d", tc.args, exitCode(err), err, tc.want)
+		}
+	}
+}
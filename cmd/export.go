@@ -0,0 +1,74 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/inflate/pkg/exporter"
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type ExportOptions struct {
+	CreateOptions
+	Format    string
+	OutputDir string
+}
+
+var (
+	exportOptions = &ExportOptions{}
+	cmdExport     = &cobra.Command{
+		Use:   "export",
+		Short: "export an inflatable or maybe a few as a kustomization or helm chart",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if exportOptions.OutputDir == "" {
+				return inflater.NewValidationError("must specify --output-dir")
+			}
+			optionsList, err := exportOptions.InflaterOptions(cmd)
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(nil)
+			var inflates []exporter.Inflate
+			for _, options := range optionsList {
+				options.DryRun = true
+				inflateCollection, err := inflate.Inflate(cmd.Context(), options)
+				if err != nil {
+					return fmt.Errorf("exporting inflate %s, %w", options.Name, err)
+				}
+				inflates = append(inflates, exporter.Inflate{Options: options, Collection: inflateCollection})
+			}
+			written, err := exporter.Export(exportOptions.Format, exportOptions.OutputDir, inflates)
+			if err != nil {
+				return err
+			}
+			for _, path := range written {
+				fmt.Printf("Wrote %s\n", path)
+			}
+			return nil
+		},
+	}
+)
+
+func init() {
+	AddCreateFlags(cmdExport, &exportOptions.CreateOptions)
+	cmdExport.Flags().StringVar(&exportOptions.Format, "format", exporter.FormatKustomize,
+		fmt.Sprintf("Export format: %v", []string{exporter.FormatKustomize, exporter.FormatHelm}))
+	cmdExport.Flags().StringVarP(&exportOptions.OutputDir, "output-dir", "d", "", "directory to write the export to")
+	rootCmd.AddCommand(cmdExport)
+}
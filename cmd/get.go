@@ -15,33 +15,70 @@ limitations under the License.
 package main
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 	appsv1 "k8s.io/api/apps/v1"
 
 	"github.com/bwagner5/inflate/pkg/inflater"
 )
 
-type GetOptions struct{}
+type GetOptions struct {
+	Watch    bool
+	Interval time.Duration
+}
 
 type GetTableOutput struct {
+	Cluster   string `table:"cluster,omitempty"`
+	Namespace string `table:"namespace"`
+	Name      string `table:"name"`
+}
+
+type GetWatchTableOutput struct {
+	Cluster   string `table:"cluster,omitempty"`
 	Namespace string `table:"namespace"`
 	Name      string `table:"name"`
+	Desired   string `table:"desired"`
+	Ready     string `table:"ready"`
+	Pending   string `table:"pending"`
+	Nodes     string `table:"nodes"`
+	NodeNames string `table:"node names,wide"`
+	Event     string `table:"recent event"`
 }
 
+const (
+	colorRed      = "\033[31m"
+	colorReset    = "\033[0m"
+	clearTerm     = "\033[H\033[2J"
+	maxEventWidth = 80
+)
+
 var (
-	cmdGet = &cobra.Command{
+	getOptions = &GetOptions{}
+	cmdGet     = &cobra.Command{
 		Use:   "get [name]",
 		Short: "get an inflatable or maybe a few",
 		Args:  cobra.MinimumNArgs(0),
-		Run: func(cmd *cobra.Command, args []string) {
-			clientset := kubeClientset()
-			inflate := inflater.New(clientset)
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if getOptions.Watch && globalOpts.Output != OutputTableShort && globalOpts.Output != OutputTableWide {
+				return inflater.NewValidationError("--watch only supports table output, not %s", globalOpts.Output)
+			}
+			if getOptions.Interval <= 0 {
+				return inflater.NewValidationError("--interval must be positive, got %s", getOptions.Interval)
+			}
+			clusters, err := kubeClusters()
+			if err != nil {
+				return err
+			}
 			listFilters := inflater.ListFilters{}
 			if rootCmd.Flag("namespace").Changed {
 				listFilters.Namespace = globalOpts.Namespace
@@ -49,38 +86,150 @@ var (
 			if len(args) > 0 {
 				listFilters.Name = args[0]
 			}
-
-			deployments, err := inflate.List(cmd.Context(), listFilters)
-			if err != nil {
-				fmt.Println(err)
-				os.Exit(1)
+			if getOptions.Watch {
+				watchStatus(cmd.Context(), clusters, listFilters)
+				return nil
 			}
 
+			clusterDeployments, err := forEachCluster(clusters, func(cluster Cluster) ([]appsv1.Deployment, error) {
+				return cluster.Inflater.List(cmd.Context(), listFilters)
+			})
+
 			switch globalOpts.Output {
 			case OutputYAML:
-				fmt.Println(PrettyEncode(deployments))
-			case OutputTableShort, OutputTableWide:
-				rows := lo.Map(deployments, func(deployment appsv1.Deployment, _ int) GetTableOutput {
-					return GetTableOutput{
-						Name:      deployment.Name,
-						Namespace: deployment.Namespace,
+				if contextOptions.FanOut() {
+					deploymentsByContext := map[string][]appsv1.Deployment{}
+					for idx, cluster := range clusters {
+						deploymentsByContext[cluster.Context] = clusterDeployments[idx]
 					}
-				})
+					fmt.Println(PrettyEncode(deploymentsByContext))
+				} else {
+					fmt.Println(PrettyEncode(clusterDeployments[0]))
+				}
+			case OutputTableShort, OutputTableWide:
+				var rows []GetTableOutput
+				for idx, deployments := range clusterDeployments {
+					rows = append(rows, lo.Map(deployments, func(deployment appsv1.Deployment, _ int) GetTableOutput {
+						return GetTableOutput{
+							Cluster:   clusters[idx].Context,
+							Name:      deployment.Name,
+							Namespace: deployment.Namespace,
+						}
+					})...)
+				}
 				sort.SliceStable(rows, func(i, j int) bool {
+					if rows[i].Cluster != rows[j].Cluster {
+						return rows[i].Cluster < rows[j].Cluster
+					}
 					if strings.EqualFold(rows[i].Namespace, rows[j].Namespace) {
 						return strings.ToLower(rows[i].Name) < strings.ToLower(rows[j].Name)
 					}
 					return strings.ToLower(rows[i].Namespace) < strings.ToLower(rows[j].Namespace)
 				})
-				fmt.Println(PrettyTable(rows, globalOpts.Output == OutputTableWide))
+				if len(rows) > 0 {
+					fmt.Println(PrettyTable(rows, globalOpts.Output == OutputTableWide))
+				} else if err == nil {
+					fmt.Println("No inflates found")
+				}
 			default:
-				fmt.Printf("unknown output options %s\n", globalOpts.Output)
-				os.Exit(1)
+				return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+			}
+			// print what was found before the errors of the namespaces or clusters that could not be listed
+			if err != nil && !contextOptions.FanOut() {
+				return fmt.Errorf("listing inflates, %w", err)
 			}
+			return clusterErrors(clusters, err)
 		},
 	}
 )
 
+// watchStatus redraws a status table of the inflates until ctx is done.
+// The screen is only cleared and unhealthy inflates only colored when stdout is a terminal.
+func watchStatus(ctx context.Context, clusters []Cluster, listFilters inflater.ListFilters) {
+	ctx, cancel := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	tty := term.IsTerminal(int(os.Stdout.Fd()))
+	for first := true; ; first = false {
+		clusterStatuses, err := forEachCluster(clusters, func(cluster Cluster) ([]inflater.InflateStatus, error) {
+			statuses, err := cluster.Inflater.Status(ctx, listFilters)
+			sort.SliceStable(statuses, func(i, j int) bool {
+				if statuses[i].Namespace == statuses[j].Namespace {
+					return statuses[i].Name < statuses[j].Name
+				}
+				return statuses[i].Namespace < statuses[j].Namespace
+			})
+			return statuses, err
+		})
+		if tty {
+			fmt.Print(clearTerm)
+		} else if !first {
+			fmt.Println()
+		}
+		fmt.Printf("Every %s: inflate get --watch\t%s\n\n", getOptions.Interval, time.Now().Format(time.RFC1123))
+		var rows []GetWatchTableOutput
+		for idx, statuses := range clusterStatuses {
+			rows = append(rows, lo.Map(statuses, func(status inflater.InflateStatus, _ int) GetWatchTableOutput {
+				return statusRow(clusters[idx].Context, status, tty)
+			})...)
+		}
+		if len(rows) > 0 {
+			fmt.Println(PrettyTable(rows, globalOpts.Output == OutputTableWide))
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(getOptions.Interval):
+		}
+	}
+}
+
+// statusRow formats a status, highlighting inflates with stuck pending or crash-looping pods when color is set
+func statusRow(cluster string, status inflater.InflateStatus, color bool) GetWatchTableOutput {
+	pending := fmt.Sprint(status.Pending)
+	if status.StuckPending > 0 {
+		pending = fmt.Sprintf("%d (%d stuck)", status.Pending, status.StuckPending)
+	}
+	if status.CrashLooping > 0 {
+		pending = fmt.Sprintf("%s (%d crash-looping)", pending, status.CrashLooping)
+	}
+	event := status.RecentEvent
+	if runes := []rune(event); len(runes) > maxEventWidth {
+		event = string(runes[:maxEventWidth-3]) + "..."
+	}
+	row := GetWatchTableOutput{
+		Cluster:   cluster,
+		Namespace: status.Namespace,
+		Name:      status.Name,
+		Desired:   fmt.Sprint(status.Desired),
+		Ready:     fmt.Sprint(status.Ready),
+		Pending:   pending,
+		Nodes:     fmt.Sprint(len(status.Nodes)),
+		NodeNames: strings.Join(status.Nodes, ","),
+		Event:     strings.ReplaceAll(event, "\n", " "),
+	}
+	if !color || !status.Unhealthy() {
+		return row
+	}
+	highlight := func(value string) string { return colorRed + value + colorReset }
+	return GetWatchTableOutput{
+		Cluster:   lo.Ternary(row.Cluster == "", "", highlight(row.Cluster)),
+		Namespace: highlight(row.Namespace),
+		Name:      highlight(row.Name),
+		Desired:   highlight(row.Desired),
+		Ready:     highlight(row.Ready),
+		Pending:   highlight(row.Pending),
+		Nodes:     highlight(row.Nodes),
+		NodeNames: highlight(row.NodeNames),
+		Event:     highlight(row.Event),
+	}
+}
+
 func init() {
+	cmdGet.Flags().BoolVarP(&getOptions.Watch, "watch", "w", false, "continuously redraw a status table of the inflates")
+	cmdGet.Flags().DurationVar(&getOptions.Interval, "interval", 2*time.Second, "how often to redraw the table with --watch")
+	AddContextFlags(cmdGet)
 	rootCmd.AddCommand(cmdGet)
 }
@@ -0,0 +1,81 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+func TestStatusRow(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		status    inflater.InflateStatus
+		color     bool
+		wantEvent string
+		wantColor bool
+	}{
+		{
+			name:      "short event",
+			status:    inflater.InflateStatus{RecentEvent: "Scheduled"},
+			wantEvent: "Scheduled",
+		},
+		{
+			name:      "long multi-byte event is truncated by rune",
+			status:    inflater.InflateStatus{RecentEvent: strings.Repeat("é", maxEventWidth+1)},
+			wantEvent: strings.Repeat("é", maxEventWidth-3) + "...",
+		},
+		{
+			name:      "newlines are flattened",
+			status:    inflater.InflateStatus{RecentEvent: "0/3 nodes\nare available"},
+			wantEvent: "0/3 nodes are available",
+		},
+		{
+			name:      "unhealthy without color",
+			status:    inflater.InflateStatus{Name: "inflate", StuckPending: 1},
+			wantEvent: "",
+		},
+		{
+			name:      "unhealthy with color",
+			status:    inflater.InflateStatus{Name: "inflate", StuckPending: 1},
+			color:     true,
+			wantColor: true,
+		},
+		{
+			name:   "healthy with color",
+			status: inflater.InflateStatus{Name: "inflate"},
+			color:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			row := statusRow("", tc.status, tc.color)
+			if gotColor := strings.Contains(row.Name, colorRed); gotColor != tc.wantColor {
+				t.Errorf("statusRow() colored = %t, want %t", gotColor, tc.wantColor)
+			}
+			if tc.wantColor {
+				return
+			}
+			if row.Event != tc.wantEvent {
+				t.Errorf("statusRow() event = %q, want %q", row.Event, tc.wantEvent)
+			}
+			if !utf8.ValidString(row.Event) {
+				t.Errorf("statusRow() event %q is not valid utf-8", row.Event)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/go-logr/logr/funcr"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+// logger writes to stderr so that it never mixes with the output of a command, it is set up before every command runs
+var logger = logr.Discard()
+
+// newLogger logs in the format to w, verbose enables the V(1) logs of the API calls, applied objects and retries
+func newLogger(w io.Writer, format string, verbose bool) (logr.Logger, error) {
+	opts := funcr.Options{LogTimestamp: true, TimestampFormat: time.RFC3339}
+	if verbose {
+		opts.Verbosity = 1
+	}
+	switch format {
+	case LogFormatText:
+		return funcr.New(func(prefix, args string) {
+			if prefix != "" {
+				args = prefix + " " + args
+			}
+			fmt.Fprintln(w, args)
+		}, opts), nil
+	case LogFormatJSON:
+		return funcr.NewJSON(func(obj string) {
+			fmt.Fprintln(w, obj)
+		}, opts), nil
+	default:
+		return logr.Discard(), inflater.NewValidationError("--log-format must be one of %v, got %q", []string{LogFormatText, LogFormatJSON}, format)
+	}
+}
+
+// contextLogger adds the kubeconfig context to the logs when fanning out to several clusters
+func contextLogger(context string) logr.Logger {
+	if context == "" {
+		return logger
+	}
+	return logger.WithValues("context", context)
+}
+
+// apiCallLogger logs every request to the API server with its latency
+type apiCallLogger struct {
+	logger logr.Logger
+	next   http.RoundTripper
+}
+
+func (a apiCallLogger) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := a.next.RoundTrip(req)
+	latency := time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		a.logger.V(1).Info("api call failed", "method", req.Method, "url", req.URL.String(), "latency", latency, "error", err.Error())
+		return resp, err
+	}
+	a.logger.V(1).Info("api call", "method", req.Method, "url", req.URL.String(), "status", resp.StatusCode, "latency", latency)
+	return resp, nil
+}
@@ -0,0 +1,137 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type PlanOptions struct {
+	CreateOptions
+	Replicas int32
+}
+
+// FitTableOutput is how many replicas fit in a quota or on a node
+type FitTableOutput struct {
+	Name     string `table:"name"`
+	Replicas string `table:"replicas"`
+	Limit    string `table:"limited by"`
+}
+
+var (
+	planOptions = &PlanOptions{}
+	cmdPlan     = &cobra.Command{
+		Use:   "plan",
+		Short: "check whether the replicas of an inflatable fit in the namespace quotas and on the current nodes",
+		Long: `check whether the replicas of an inflatable fit in the namespace quotas and on the current nodes
+
+The requests of the pods, after the LimitRange defaults, are compared against the ResourceQuotas and LimitRanges
+of the namespace and against the allocatable capacity left on the schedulable nodes that match the node selector
+and whose taints are tolerated. Nothing is created.`,
+		Args: cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			optionsList, err := planOptions.InflaterOptions(cmd)
+			if err != nil {
+				return err
+			}
+			if planOptions.Replicas < 0 {
+				return inflater.NewValidationError("--replicas must not be negative")
+			}
+			clientset, err := kubeClientset("")
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(clientset).WithLogger(logger)
+			var plans []*inflater.Plan
+			for _, options := range optionsList {
+				replicas := planOptions.Replicas
+				if !cmd.Flag("replicas").Changed {
+					replicas = lo.Ternary(options.HPA != nil, lo.FromPtr(options.HPA).MaxReplicas, int32(1))
+				}
+				plan, err := inflate.Plan(cmd.Context(), inflater.PlanOptions{Options: options, Replicas: replicas})
+				if err != nil {
+					return fmt.Errorf("planning inflate %s, %w", options.Name, err)
+				}
+				plans = append(plans, plan)
+			}
+			switch globalOpts.Output {
+			case OutputYAML:
+				fmt.Println(PrettyEncode(plans))
+			case OutputTableShort, OutputTableWide:
+				for _, plan := range plans {
+					fmt.Println(FormatPlan(*plan, globalOpts.Output == OutputTableWide))
+				}
+			default:
+				return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+			}
+			return nil
+		},
+	}
+)
+
+// FormatPlan summarizes where the replicas of an inflate can run, the nodes are only listed when wide
+func FormatPlan(plan inflater.Plan, wide bool) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s/%s: %d replicas requesting %s (%s per pod)\n",
+		plan.Namespace, plan.Name, plan.Replicas, formatResources(plan.TotalRequests), formatResources(plan.PodRequests)))
+	out.WriteString(fmt.Sprintf("Fit now:        %d on %d/%d matching nodes\n", plan.FitNow, len(plan.Nodes), plan.TotalNodes))
+	out.WriteString(fmt.Sprintf("Need new nodes: %d\n", plan.NeedNewNodes))
+	out.WriteString(fmt.Sprintf("Quota blocked:  %d\n", plan.QuotaBlocked))
+	if len(plan.Problems) > 0 {
+		out.WriteString("\nProblems:\n")
+		for _, problem := range plan.Problems {
+			out.WriteString(fmt.Sprintf("  - %s\n", problem))
+		}
+	}
+	if len(plan.Quotas) > 0 {
+		out.WriteString("\nResource quotas:\n")
+		out.WriteString(PrettyTable(fitRows(plan.Quotas), wide))
+	}
+	if wide && len(plan.Nodes) > 0 {
+		out.WriteString("\nNodes:\n")
+		out.WriteString(PrettyTable(fitRows(plan.Nodes), wide))
+	}
+	return out.String()
+}
+
+func fitRows(fits []inflater.Fit) []FitTableOutput {
+	return lo.Map(fits, func(fit inflater.Fit, _ int) FitTableOutput {
+		return FitTableOutput{Name: fit.Name, Replicas: fmt.Sprint(fit.Replicas), Limit: fit.Limit}
+	})
+}
+
+// formatResources formats a resource list as name=quantity sorted by name
+func formatResources(resources corev1.ResourceList) string {
+	names := lo.Map(lo.Keys(resources), func(name corev1.ResourceName, _ int) string { return string(name) })
+	sort.Strings(names)
+	return strings.Join(lo.Map(names, func(name string, _ int) string {
+		quantity := resources[corev1.ResourceName(name)]
+		return fmt.Sprintf("%s=%s", name, quantity.String())
+	}), ",")
+}
+
+func init() {
+	AddCreateFlags(cmdPlan, &planOptions.CreateOptions)
+	cmdPlan.Flags().Int32Var(&planOptions.Replicas, "replicas", 0, "number of replicas to plan for (default the HPA max replicas or 1)")
+	rootCmd.AddCommand(cmdPlan)
+}
@@ -0,0 +1,121 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type RenderOptions struct {
+	CreateOptions
+	OutputDir string
+}
+
+var (
+	renderOptions = &RenderOptions{}
+	cmdRender     = &cobra.Command{
+		Use:   "render",
+		Short: "render the manifests of an inflatable or maybe a few without a cluster",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			optionsList, err := renderOptions.InflaterOptions(cmd)
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(nil)
+			var namespaces []string
+			var collections []*inflater.InflateCollection
+			for _, options := range optionsList {
+				options.DryRun = true
+				inflateCollection, err := inflate.Inflate(cmd.Context(), options)
+				if err != nil {
+					return fmt.Errorf("rendering inflate %s, %w", options.Name, err)
+				}
+				namespaces = append(namespaces, options.Namespace)
+				collections = append(collections, inflateCollection)
+			}
+			namespaces = lo.Uniq(namespaces)
+
+			if renderOptions.OutputDir == "" {
+				objects := lo.Map(namespaces, func(namespace string, _ int) runtime.Object { return inflate.GetNamespace(namespace) })
+				for _, inflateCollection := range collections {
+					objects = append(objects, inflateCollection.Objects()...)
+				}
+				manifests, err := RenderYAML(objects...)
+				if err != nil {
+					return err
+				}
+				fmt.Print(manifests)
+				return nil
+			}
+
+			if err := os.MkdirAll(renderOptions.OutputDir, 0o755); err != nil {
+				return err
+			}
+			files := map[string][]runtime.Object{}
+			for _, namespace := range namespaces {
+				files[fmt.Sprintf("namespace-%s.yaml", namespace)] = []runtime.Object{inflate.GetNamespace(namespace)}
+			}
+			for _, inflateCollection := range collections {
+				fileName := fmt.Sprintf("%s-%s.yaml", inflateCollection.Deployment.Namespace, inflateCollection.Deployment.Name)
+				files[fileName] = inflateCollection.Objects()
+			}
+			fileNames := lo.Keys(files)
+			sort.Strings(fileNames)
+			for _, fileName := range fileNames {
+				manifests, err := RenderYAML(files[fileName]...)
+				if err != nil {
+					return err
+				}
+				path := filepath.Join(renderOptions.OutputDir, fileName)
+				//nolint:gosec
+				if err := os.WriteFile(path, []byte(manifests), 0o644); err != nil {
+					return err
+				}
+				fmt.Printf("Wrote %s\n", path)
+			}
+			return nil
+		},
+	}
+)
+
+// RenderYAML renders objects as an apply-ready multi-document YAML stream
+func RenderYAML(objects ...runtime.Object) (string, error) {
+	var documents []string
+	for _, obj := range objects {
+		document, err := inflater.NormalizedYAML(obj)
+		if err != nil {
+			return "", err
+		}
+		documents = append(documents, document)
+	}
+	return strings.Join(documents, "---\n"), nil
+}
+
+func init() {
+	AddCreateFlags(cmdRender, &renderOptions.CreateOptions)
+	cmdRender.Flags().StringVarP(&renderOptions.OutputDir, "output-dir", "d", "", "write one file per inflate into this directory instead of stdout")
+	rootCmd.AddCommand(cmdRender)
+}
@@ -0,0 +1,183 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+	"github.com/bwagner5/inflate/pkg/report"
+)
+
+// ReportOptions are shared by the commands that can record a --report
+type ReportOptions struct {
+	Report        string
+	ReportTimeout time.Duration
+	NodeTimeline  bool
+}
+
+type NodeSummaryTableOutput struct {
+	InstanceType   string `table:"instance type"`
+	Zone           string `table:"zone"`
+	CapacityType   string `table:"capacity type"`
+	Added          string `table:"added"`
+	Removed        string `table:"removed"`
+	AvgTimeToReady string `table:"avg time to ready"`
+}
+
+type NodeTimelineTableOutput struct {
+	Name          string `table:"name"`
+	InstanceType  string `table:"instance type"`
+	Zone          string `table:"zone"`
+	CapacityType  string `table:"capacity type"`
+	Created       string `table:"created"`
+	Ready         string `table:"ready"`
+	FirstPodBound string `table:"first pod bound"`
+	Deleted       string `table:"deleted"`
+}
+
+// AddReportFlags registers the recording flags, done describes when recording stops
+func AddReportFlags(cmd *cobra.Command, opts *ReportOptions, done string) {
+	cmd.Flags().StringVar(&opts.Report, "report", "", fmt.Sprintf("write a JSON report of the run, recorded until %s, to this file", done))
+	cmd.Flags().DurationVar(&opts.ReportTimeout, "report-timeout", 10*time.Minute, fmt.Sprintf("how long to wait until %s when recording", done))
+	cmd.Flags().BoolVar(&opts.NodeTimeline, "node-timeline", false, fmt.Sprintf("track the nodes created and deleted until %s and print a summary", done))
+}
+
+// Recording is true when a report or node timeline was requested
+func (o ReportOptions) Recording() bool {
+	return o.Report != "" || o.NodeTimeline
+}
+
+// startRecorder starts recording the run and the events in the namespaces when a report or node timeline was requested,
+// returning nil otherwise
+func startRecorder(ctx context.Context, clientset *kubernetes.Clientset, opts ReportOptions, namespaces []string) (*report.Recorder, error) {
+	if !opts.Recording() {
+		return nil, nil
+	}
+	if opts.ReportTimeout <= 0 {
+		return nil, inflater.NewValidationError("--report-timeout must be positive, got %s", opts.ReportTimeout)
+	}
+	recorder := report.NewRecorder(clientset, namespaces)
+	if err := recorder.Start(ctx); err != nil {
+		return nil, fmt.Errorf("starting the recorder, %w", err)
+	}
+	return recorder, nil
+}
+
+// writeReport waits up to the report timeout for done to be true of the recorded pods,
+// then writes the report and prints the node timeline if requested. It returns a TimeoutError if done was not reached.
+func writeReport(ctx context.Context, recorder *report.Recorder, opts ReportOptions, command string, options any,
+	resources []report.Resource, done func(pods []report.PodTimeline) bool) error {
+	if recorder == nil {
+		return nil
+	}
+	defer recorder.Stop()
+	waitCtx, cancel := context.WithTimeout(ctx, opts.ReportTimeout)
+	defer cancel()
+	fmt.Fprintf(os.Stderr, "Recording until done or %s\n", opts.ReportTimeout)
+	timedOut := !recorder.Wait(waitCtx, done)
+	runReport := recorder.Report(command, options, resources)
+	runReport.TimedOut = timedOut
+	runReport.Summarize()
+	if opts.NodeTimeline {
+		fmt.Println(FormatNodeTimeline(runReport, globalOpts.Output == OutputTableWide))
+	}
+	if opts.Report != "" {
+		if err := runReport.Write(opts.Report); err != nil {
+			return fmt.Errorf("writing report, %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Wrote report %s\n", opts.Report)
+	}
+	if timedOut {
+		return &inflater.TimeoutError{Operation: fmt.Sprintf("waiting for %s to finish", command), Timeout: opts.ReportTimeout}
+	}
+	return nil
+}
+
+// FormatNodeTimeline prints the node summary and time-to-capacity, wide adds every node's timeline
+func FormatNodeTimeline(runReport report.Report, wide bool) string {
+	var out strings.Builder
+	if len(runReport.NodeSummary) == 0 {
+		out.WriteString("No nodes were added or removed\n")
+	} else {
+		out.WriteString(PrettyTable(lo.Map(runReport.NodeSummary, func(summary report.NodeSummary, _ int) NodeSummaryTableOutput {
+			return NodeSummaryTableOutput{
+				InstanceType:   lo.Ternary(summary.InstanceType == "", "-", summary.InstanceType),
+				Zone:           lo.Ternary(summary.Zone == "", "-", summary.Zone),
+				CapacityType:   lo.Ternary(summary.CapacityType == "", "-", summary.CapacityType),
+				Added:          fmt.Sprint(summary.Added),
+				Removed:        fmt.Sprint(summary.Removed),
+				AvgTimeToReady: formatSeconds(summary.AvgTimeToReadySeconds),
+			}
+		}), false))
+	}
+	if wide && len(runReport.Nodes) > 0 {
+		out.WriteString("\n")
+		out.WriteString(PrettyTable(lo.Map(runReport.Nodes, func(node report.NodeTimeline, _ int) NodeTimelineTableOutput {
+			return NodeTimelineTableOutput{
+				Name:          node.Name,
+				InstanceType:  node.InstanceType,
+				Zone:          node.Zone,
+				CapacityType:  node.CapacityType,
+				Created:       formatSince(runReport.StartTime, &node.Created),
+				Ready:         formatSince(runReport.StartTime, node.Ready),
+				FirstPodBound: formatSince(runReport.StartTime, node.FirstPodBound),
+				Deleted:       formatSince(runReport.StartTime, node.Deleted),
+			}
+		}), false))
+	}
+	switch {
+	case runReport.Command != "create":
+	case runReport.TimedOut:
+		out.WriteString("Time to capacity: timed out\n")
+	default:
+		out.WriteString(fmt.Sprintf("Time to capacity: %s\n", formatSeconds(runReport.TimeToCapacitySeconds)))
+	}
+	return out.String()
+}
+
+func formatSeconds(seconds *float64) string {
+	if seconds == nil {
+		return "-"
+	}
+	return (time.Duration(*seconds * float64(time.Second))).Round(time.Second).String()
+}
+
+// formatSince formats t relative to the start of the run, e.g. +1m5s
+func formatSince(start time.Time, t *time.Time) string {
+	if t == nil {
+		return "-"
+	}
+	since := t.Sub(start).Round(time.Second)
+	if since < 0 {
+		return since.String()
+	}
+	return "+" + since.String()
+}
+
+// podsOf returns the recorded pods of a deployment resource
+func podsOf(pods []report.PodTimeline, resource report.Resource) []report.PodTimeline {
+	return lo.Filter(pods, func(pod report.PodTimeline, _ int) bool {
+		return pod.Namespace == resource.Namespace && pod.Inflate == resource.Name
+	})
+}
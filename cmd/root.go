@@ -17,9 +17,11 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
-	"path/filepath"
 	"reflect"
 	"strings"
 
@@ -29,8 +31,11 @@ import (
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
 	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
-	"k8s.io/client-go/util/homedir"
+	"k8s.io/klog/v2"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
 )
 
 const (
@@ -45,9 +50,15 @@ var (
 
 type GlobalOptions struct {
 	Verbose    bool
+	LogFormat  string
 	Version    bool
 	Output     string
 	Kubeconfig string
+	Context    string
+	Cluster    string
+	User       string
+	QPS        float32
+	Burst      int
 	Namespace  string
 	ConfigFile string
 }
@@ -55,21 +66,38 @@ type GlobalOptions struct {
 var (
 	globalOpts = GlobalOptions{}
 	rootCmd    = &cobra.Command{
-		Use:     "inflate",
-		Version: version,
+		Use:           "inflate",
+		Version:       version,
+		Long:          exitCodesHelp,
+		SilenceErrors: true,
+		SilenceUsage:  true,
+		// the root command runs so that an unknown command fails its Args rather than printing the help
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return cmd.Help()
+		},
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			var err error
+			if logger, err = newLogger(os.Stderr, globalOpts.LogFormat, globalOpts.Verbose); err != nil {
+				return err
+			}
+			// client-go logs, e.g. about client-side throttling, use the same format
+			klog.SetLogger(logger)
+			return nil
+		},
 	}
 )
 
 func main() {
-	var defaultKubeconfigPath string
-	if kconfig, ok := os.LookupEnv("KUBECONFIG"); ok {
-		defaultKubeconfigPath = kconfig
-	} else {
-		defaultKubeconfigPath = lo.Ternary(homedir.HomeDir() != "", filepath.Join(homedir.HomeDir(), ".kube", "config"), "")
-	}
-	rootCmd.PersistentFlags().StringVarP(&globalOpts.Kubeconfig, "kubeconfig", "k", defaultKubeconfigPath, "path to the kubeconfig file")
+	rootCmd.PersistentFlags().StringVarP(&globalOpts.Kubeconfig, "kubeconfig", "k", "", "path to the kubeconfig file (default $KUBECONFIG or ~/.kube/config, then in-cluster)")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.Context, "context", "", "kubeconfig context to use")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.Cluster, "cluster", "", "kubeconfig cluster to use")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.User, "user", "", "kubeconfig user to use")
+	rootCmd.PersistentFlags().Float32Var(&globalOpts.QPS, "qps", rest.DefaultQPS, "queries per second allowed by the client-side rate limiter")
+	rootCmd.PersistentFlags().IntVar(&globalOpts.Burst, "burst", rest.DefaultBurst, "burst allowed by the client-side rate limiter")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.Namespace, "namespace", "n", "inflate", "k8s namespace")
-	rootCmd.PersistentFlags().BoolVar(&globalOpts.Verbose, "verbose", false, "Verbose output")
+	rootCmd.PersistentFlags().BoolVar(&globalOpts.Verbose, "verbose", false, "log every API call with its latency, the objects applied and deleted, and retries to stderr")
+	rootCmd.PersistentFlags().StringVar(&globalOpts.LogFormat, "log-format", LogFormatText, fmt.Sprintf("Log format: %v", []string{LogFormatText, LogFormatJSON}))
 	rootCmd.PersistentFlags().BoolVar(&globalOpts.Version, "version", false, "version")
 	rootCmd.PersistentFlags().StringVarP(&globalOpts.Output, "output", "o", OutputTableShort,
 		fmt.Sprintf("Output mode: %v", []string{OutputTableShort, OutputTableWide, OutputYAML}))
@@ -78,21 +106,71 @@ func main() {
 	rootCmd.AddCommand(&cobra.Command{Use: "completion", Hidden: true})
 	cobra.EnableCommandSorting = false
 
-	lo.Must0(rootCmd.Execute())
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return &inflater.ValidationError{Err: err}
+	})
+	// unknown commands and wrong numbers of arguments are invalid input like unknown flags
+	validateArgs(rootCmd)
+
+	if cmd, err := rootCmd.ExecuteC(); err != nil {
+		printError(cmd, err)
+		os.Exit(exitCode(err))
+	}
+}
+
+// validateArgs makes the positional argument errors of cmd and its subcommands ValidationErrors
+func validateArgs(cmd *cobra.Command) {
+	if args := cmd.Args; args != nil {
+		cmd.Args = func(cmd *cobra.Command, positional []string) error {
+			if err := args(cmd, positional); err != nil {
+				return &inflater.ValidationError{Err: err}
+			}
+			return nil
+		}
+	}
+	for _, subcommand := range cmd.Commands() {
+		validateArgs(subcommand)
+	}
 }
 
-func kubeClientset() *kubernetes.Clientset {
-	config, err := clientcmd.BuildConfigFromFlags("", globalOpts.Kubeconfig)
+// kubeConfig loads the kubeconfig like kubectl, merging the $KUBECONFIG list and applying the overrides,
+// and falls back to the in-cluster config when there is no kubeconfig
+func kubeConfig(context string) (*rest.Config, error) {
+	config, err := kubeClientConfig(context).ClientConfig()
+	if clientcmd.IsEmptyConfig(err) {
+		config, err = rest.InClusterConfig()
+	}
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, err
 	}
-	clientset, err := kubernetes.NewForConfig(config)
+	config.QPS = globalOpts.QPS
+	config.Burst = globalOpts.Burst
+	config.Wrap(func(rt http.RoundTripper) http.RoundTripper {
+		return apiCallLogger{logger: contextLogger(context), next: rt}
+	})
+	return config, nil
+}
+
+// kubeClientConfig loads the kubeconfig for a context, without a context the --context, --cluster and --user flags apply
+func kubeClientConfig(context string) clientcmd.ClientConfig {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	loadingRules.ExplicitPath = globalOpts.Kubeconfig
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: context}
+	if context == "" {
+		overrides.CurrentContext = globalOpts.Context
+		overrides.Context.Cluster = globalOpts.Cluster
+		overrides.Context.AuthInfo = globalOpts.User
+	}
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+}
+
+// kubeClientset returns a clientset for the context, without a context the --context, --cluster and --user flags apply
+func kubeClientset(context string) (*kubernetes.Clientset, error) {
+	config, err := kubeConfig(context)
 	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+		return nil, fmt.Errorf("loading kubeconfig, %w", err)
 	}
-	return clientset
+	return kubernetes.NewForConfig(config)
 }
 
 func ParseConfig[T any](globalOpts GlobalOptions, opts T) (T, error) {
@@ -113,6 +191,37 @@ func ParseConfig[T any](globalOpts GlobalOptions, opts T) (T, error) {
 	return opts, nil
 }
 
+// ParseConfigs parses a multi-document YAML config file, merging each document over opts.
+// A scenario file describes one inflate per document.
+func ParseConfigs[T any](globalOpts GlobalOptions, opts T) ([]T, error) {
+	if globalOpts.ConfigFile == "" {
+		return []T{opts}, nil
+	}
+	configBytes, err := os.ReadFile(globalOpts.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	var configs []T
+	decoder := yaml.NewDecoder(bytes.NewReader(configBytes))
+	for {
+		var parsedOpts T
+		if err := decoder.Decode(&parsedOpts); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		mergedOpts := opts
+		if err := mergo.Merge(&mergedOpts, parsedOpts, mergo.WithOverride); err != nil {
+			return nil, err
+		}
+		configs = append(configs, mergedOpts)
+	}
+	if len(configs) == 0 {
+		return []T{opts}, nil
+	}
+	return configs, nil
+}
+
 func PrettyEncode(data any) string {
 	var buffer bytes.Buffer
 	enc := json.NewEncoder(&buffer)
@@ -123,9 +232,18 @@ func PrettyEncode(data any) string {
 	return buffer.String()
 }
 
+// PrettyTable renders the fields with a table tag, "wide" columns are only shown when wide
+// and "omitempty" columns are dropped when they are empty in every row
 func PrettyTable[T any](data []T, wide bool) string {
 	var headers []string
 	var rows [][]string
+	nonEmpty := map[int]bool{}
+	for _, dataRow := range data {
+		reflectStruct := reflect.Indirect(reflect.ValueOf(dataRow))
+		for i := 0; i < reflectStruct.NumField(); i++ {
+			nonEmpty[i] = nonEmpty[i] || reflectStruct.Field(i).String() != ""
+		}
+	}
 	for _, dataRow := range data {
 		var row []string
 		// clear headers each time so we only keep one set
@@ -138,7 +256,10 @@ func PrettyTable[T any](data []T, wide bool) string {
 				continue
 			}
 			subtags := strings.Split(tag, ",")
-			if len(subtags) > 1 && subtags[1] == "wide" && !wide {
+			if lo.Contains(subtags[1:], "wide") && !wide {
+				continue
+			}
+			if lo.Contains(subtags[1:], "omitempty") && !nonEmpty[i] {
 				continue
 			}
 			headers = append(headers, subtags[0])
@@ -0,0 +1,185 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type SimulateOptions struct {
+	CreateOptions
+	Nodes    string
+	Replicas int32
+}
+
+// scenarioReplicas is the number of replicas of an inflate in a scenario file, the other keys are those of create
+type scenarioReplicas struct {
+	Replicas int32 `yaml:"replicas"`
+}
+
+// PlacementTableOutput is the number of replicas of an inflate placed on a node
+type PlacementTableOutput struct {
+	Node     string `table:"node"`
+	Replicas string `table:"replicas"`
+}
+
+// SimulatedNodeTableOutput is what the simulated replicas request of a node
+type SimulatedNodeTableOutput struct {
+	Node   string `table:"node"`
+	Pods   string `table:"pods"`
+	CPU    string `table:"cpu"`
+	Memory string `table:"memory"`
+}
+
+var (
+	simulateOptions = &SimulateOptions{}
+	cmdSimulate     = &cobra.Command{
+		Use:   "simulate",
+		Short: "simulate scheduling the replicas of inflatables on a node inventory without a cluster",
+		Long: fmt.Sprintf(`simulate scheduling the replicas of inflatables on a node inventory without a cluster
+
+The replicas of each inflate in the scenario (-f, with an optional replicas key per inflate) are placed
+one at a time by a simplified scheduler that checks resources, node selectors, tolerations, required pod
+anti-affinity and topology spread constraints. The node inventory (--nodes) is a yaml list of nodes:
+
+  - name: spot-a
+    count: 3
+    labels:
+      topology.kubernetes.io/zone: us-west-2a
+      karpenter.sh/capacity-type: spot
+    taints:
+    - key: dedicated
+      value: batch
+      effect: NoSchedule
+    allocatable:
+      cpu: "4"
+      memory: 16Gi
+
+or the output of kubectl get nodes -o yaml. Exits %d when replicas are unschedulable.`, ExitUnschedulable),
+		Args: cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if simulateOptions.Nodes == "" {
+				return inflater.NewValidationError("--nodes is required")
+			}
+			if simulateOptions.Replicas < 0 {
+				return inflater.NewValidationError("--replicas must not be negative")
+			}
+			optionsList, err := simulateOptions.InflaterOptions(cmd)
+			if err != nil {
+				return err
+			}
+			replicasList, err := ParseConfigs(globalOpts, scenarioReplicas{Replicas: simulateOptions.Replicas})
+			if err != nil {
+				return &inflater.ValidationError{Err: fmt.Errorf("parsing %s, %w", globalOpts.ConfigFile, err)}
+			}
+			nodesBytes, err := os.ReadFile(simulateOptions.Nodes)
+			if err != nil {
+				return &inflater.ValidationError{Err: err}
+			}
+			nodes, err := inflater.ParseNodeInventory(nodesBytes)
+			if err != nil {
+				return &inflater.ValidationError{Err: fmt.Errorf("parsing %s, %w", simulateOptions.Nodes, err)}
+			}
+			inflates := lo.Map(optionsList, func(options inflater.Options, idx int) inflater.PlanOptions {
+				replicas := replicasList[idx].Replicas
+				if replicas == 0 {
+					replicas = lo.Ternary(options.HPA != nil, lo.FromPtr(options.HPA).MaxReplicas, int32(1))
+				}
+				return inflater.PlanOptions{Options: options, Replicas: replicas}
+			})
+			simulation, err := inflater.New(nil).Simulate(cmd.Context(), inflater.SimulateOptions{Inflates: inflates, Nodes: nodes})
+			if err != nil {
+				return fmt.Errorf("simulating, %w", err)
+			}
+			switch globalOpts.Output {
+			case OutputYAML:
+				fmt.Println(PrettyEncode(simulation))
+			case OutputTableShort, OutputTableWide:
+				fmt.Println(FormatSimulation(*simulation, globalOpts.Output == OutputTableWide))
+			default:
+				return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+			}
+			if lo.SomeBy(simulation.Inflates, func(inflate inflater.InflateSimulation) bool { return inflate.Unschedulable > 0 }) {
+				return errUnschedulable
+			}
+			return nil
+		},
+	}
+)
+
+// FormatSimulation describes the placement of each inflate and why replicas were unschedulable, the nodes are only listed when wide
+func FormatSimulation(simulation inflater.Simulation, wide bool) string {
+	var out strings.Builder
+	for idx, inflate := range simulation.Inflates {
+		if idx > 0 {
+			out.WriteString("\n")
+		}
+		out.WriteString(fmt.Sprintf("%s/%s: %d/%d replicas scheduled", inflate.Namespace, inflate.Name, inflate.Scheduled, inflate.Replicas))
+		if inflate.Unschedulable > 0 {
+			out.WriteString(fmt.Sprintf(", %d unschedulable", inflate.Unschedulable))
+		}
+		out.WriteString("\n")
+		if len(inflate.Placements) > 0 {
+			out.WriteString(PrettyTable(lo.Map(inflate.Placements, func(placement inflater.Placement, _ int) PlacementTableOutput {
+				return PlacementTableOutput{Node: placement.Node, Replicas: fmt.Sprint(placement.Replicas)}
+			}), wide))
+		}
+		if len(inflate.Reasons) > 0 {
+			out.WriteString(fmt.Sprintf("Unschedulable: 0/%d nodes are available: %s\n", simulation.TotalNodes,
+				strings.Join(lo.Map(inflate.Reasons, func(reason inflater.SchedulingReason, _ int) string {
+					return fmt.Sprintf("%d %s", reason.Nodes, reason.Category)
+				}), ", ")))
+		}
+		if len(inflate.LikelyCauses) > 0 {
+			out.WriteString("Likely causes:\n")
+			for _, cause := range inflate.LikelyCauses {
+				out.WriteString(fmt.Sprintf("  - %s\n", cause))
+			}
+		}
+	}
+	if wide && len(simulation.Nodes) > 0 {
+		out.WriteString("\nNodes:\n")
+		out.WriteString(PrettyTable(lo.Map(simulation.Nodes, func(node inflater.NodeSimulation, _ int) SimulatedNodeTableOutput {
+			return SimulatedNodeTableOutput{
+				Node:   node.Name,
+				Pods:   fmt.Sprint(node.Pods),
+				CPU:    usage(node, corev1.ResourceCPU),
+				Memory: usage(node, corev1.ResourceMemory),
+			}
+		}), wide))
+	}
+	return out.String()
+}
+
+// usage formats the requested and allocatable quantity of a resource of a node
+func usage(node inflater.NodeSimulation, name corev1.ResourceName) string {
+	requested, allocatable := node.Requested[name], node.Allocatable[name]
+	return fmt.Sprintf("%s/%s", requested.String(), allocatable.String())
+}
+
+func init() {
+	AddCreateFlags(cmdSimulate, &simulateOptions.CreateOptions)
+	cmdSimulate.Flags().StringVar(&simulateOptions.Nodes, "nodes", "", "yaml file with the node inventory to schedule on")
+	cmdSimulate.Flags().Int32Var(&simulateOptions.Replicas, "replicas", 0, "number of replicas of each inflate without a replicas key in the scenario (default the HPA max replicas or 1)")
+	rootCmd.AddCommand(cmdSimulate)
+}
@@ -0,0 +1,89 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/inflate/pkg/watcher"
+)
+
+type WatchOptions struct {
+	MetricsAddr string
+}
+
+var (
+	watchOptions = &WatchOptions{}
+	cmdWatch     = &cobra.Command{
+		Use:   "watch",
+		Short: "watch inflatables and serve prometheus metrics about them",
+		Args:  cobra.MinimumNArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctx, cancel := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer cancel()
+			namespace := ""
+			if rootCmd.Flag("namespace").Changed {
+				namespace = globalOpts.Namespace
+			}
+			clientset, err := kubeClientset("")
+			if err != nil {
+				return err
+			}
+			inflateWatcher := watcher.New(clientset, namespace)
+
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", promhttp.HandlerFor(inflateWatcher.Registry(), promhttp.HandlerOpts{}))
+			server := &http.Server{
+				Addr:              watchOptions.MetricsAddr,
+				Handler:           mux,
+				ReadHeaderTimeout: 10 * time.Second,
+			}
+			serverErr := make(chan error, 1)
+			go func() {
+				if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					serverErr <- fmt.Errorf("serving metrics, %w", err)
+					cancel()
+				}
+			}()
+			fmt.Fprintf(os.Stderr, "Serving metrics on %s/metrics\n", watchOptions.MetricsAddr)
+
+			err = inflateWatcher.Run(ctx)
+			shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer shutdownCancel()
+			_ = server.Shutdown(shutdownCtx)
+			select {
+			case serveErr := <-serverErr:
+				return serveErr
+			default:
+				return err
+			}
+		},
+	}
+)
+
+func init() {
+	cmdWatch.Flags().StringVar(&watchOptions.MetricsAddr, "metrics-addr", ":9090", "address to serve prometheus metrics on")
+	rootCmd.AddCommand(cmdWatch)
+}
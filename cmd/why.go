@@ -0,0 +1,94 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/samber/lo"
+	"github.com/spf13/cobra"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+type WhyTableOutput struct {
+	Nodes   string `table:"nodes"`
+	Reason  string `table:"reason"`
+	Details string `table:"details,wide"`
+}
+
+var (
+	cmdWhy = &cobra.Command{
+		Use:   "why <name>",
+		Short: "explain why an inflatable's pods are pending",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			clientset, err := kubeClientset("")
+			if err != nil {
+				return err
+			}
+			inflate := inflater.New(clientset).WithLogger(logger)
+			listFilters := inflater.ListFilters{Name: args[0]}
+			if rootCmd.Flag("namespace").Changed {
+				listFilters.Namespace = globalOpts.Namespace
+			}
+			diagnoses, err := inflate.Why(cmd.Context(), listFilters)
+			switch globalOpts.Output {
+			case OutputYAML:
+				fmt.Println(PrettyEncode(diagnoses))
+			case OutputTableShort, OutputTableWide:
+				for _, diagnosis := range diagnoses {
+					fmt.Println(FormatDiagnosis(diagnosis, globalOpts.Output == OutputTableWide))
+				}
+			default:
+				return inflater.NewValidationError("unknown output options %s", globalOpts.Output)
+			}
+			return err
+		},
+	}
+)
+
+// FormatDiagnosis describes the scheduling failures of an inflate and their likely causes
+func FormatDiagnosis(diagnosis inflater.SchedulingDiagnosis, wide bool) string {
+	var out strings.Builder
+	out.WriteString(fmt.Sprintf("%s/%s: %d pending pods, %d with FailedScheduling events\n",
+		diagnosis.Namespace, diagnosis.Name, diagnosis.PendingPods, diagnosis.FailedSchedulingPods))
+	if diagnosis.LatestMessage == "" {
+		return out.String()
+	}
+	out.WriteString(fmt.Sprintf("Latest: %s\n\n", diagnosis.LatestMessage))
+	if len(diagnosis.Reasons) > 0 {
+		rows := lo.Map(diagnosis.Reasons, func(reason inflater.SchedulingReason, _ int) WhyTableOutput {
+			return WhyTableOutput{
+				Nodes:   fmt.Sprintf("%d/%d", reason.Nodes, diagnosis.TotalNodes),
+				Reason:  reason.Category,
+				Details: strings.Join(reason.Details, "; "),
+			}
+		})
+		out.WriteString(PrettyTable(rows, wide))
+	}
+	if len(diagnosis.LikelyCauses) > 0 {
+		out.WriteString("\nLikely causes:\n")
+		for _, cause := range diagnosis.LikelyCauses {
+			out.WriteString(fmt.Sprintf("  - %s\n", cause))
+		}
+	}
+	return out.String()
+}
+
+func init() {
+	rootCmd.AddCommand(cmdWhy)
+}
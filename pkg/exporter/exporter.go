@@ -0,0 +1,85 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/samber/lo"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+const (
+	FormatKustomize = "kustomize"
+	FormatHelm      = "helm"
+)
+
+// Inflate is a single rendered inflate of a scenario
+type Inflate struct {
+	Options    inflater.Options
+	Collection *inflater.InflateCollection
+}
+
+// Export writes the inflates to dir in the requested format
+func Export(format string, dir string, inflates []Inflate) ([]string, error) {
+	switch format {
+	case FormatKustomize:
+		return Kustomize(dir, inflates)
+	case FormatHelm:
+		return Helm(dir, inflates)
+	default:
+		return nil, inflater.NewValidationError("unknown export format %s, must be one of %v", format, []string{FormatKustomize, FormatHelm})
+	}
+}
+
+// namespaces returns the unique namespaces of the inflates in order
+func namespaces(inflates []Inflate) []string {
+	return lo.Uniq(lo.Map(inflates, func(inflate Inflate, _ int) string { return inflate.Collection.Deployment.Namespace }))
+}
+
+// fileWriter writes files relative to a root directory and records the paths written
+type fileWriter struct {
+	root    string
+	written []string
+}
+
+func (w *fileWriter) write(path string, contents string) error {
+	fullPath := filepath.Join(w.root, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0o755); err != nil {
+		return err
+	}
+	//nolint:gosec
+	if err := os.WriteFile(fullPath, []byte(contents), 0o644); err != nil {
+		return err
+	}
+	w.written = append(w.written, fullPath)
+	return nil
+}
+
+func (w *fileWriter) writeYAML(path string, documents ...any) error {
+	var rendered []string
+	for _, document := range documents {
+		out, err := yaml.Marshal(document)
+		if err != nil {
+			return err
+		}
+		rendered = append(rendered, string(out))
+	}
+	return w.write(path, strings.Join(rendered, "---\n"))
+}
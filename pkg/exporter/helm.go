@@ -0,0 +1,361 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+// HelmValues are the values of the exported chart
+type HelmValues struct {
+	Inflates []HelmInflateValues `json:"inflates"`
+}
+
+// HelmInflateValues map onto inflater.Options, names are already resolved so there is no random suffix
+type HelmInflateValues struct {
+	Name               string `json:"name"`
+	Namespace          string `json:"namespace"`
+	Image              string `json:"image"`
+	ZonalSpread        bool   `json:"zonalSpread"`
+	HostnameSpread     bool   `json:"hostnameSpread"`
+	CapacityTypeSpread bool   `json:"capacityTypeSpread"`
+	AntiAffinity       string `json:"antiAffinity"`
+	// Tolerations are rendered as they are into the pod spec
+	Tolerations        []corev1.Toleration `json:"tolerations,omitempty"`
+	HostNetwork        bool                `json:"hostNetwork"`
+	CPUArch            string              `json:"cpuArch"`
+	OS                 string              `json:"os"`
+	NodePool           string              `json:"nodePool"`
+	CapacityType       string              `json:"capacityType"`
+	InstanceType       string              `json:"instanceType"`
+	InstanceFamily     string              `json:"instanceFamily"`
+	DoNotDisrupt       bool                `json:"doNotDisrupt"`
+	Service            bool                `json:"service"`
+	PriorityClassName  string              `json:"priorityClassName"`
+	PriorityClassValue *int32              `json:"priorityClassValue,omitempty"`
+	PreemptionPolicy   string              `json:"preemptionPolicy"`
+	Protect            bool                `json:"protect"`
+	HPA                *HelmHPAValues      `json:"hpa,omitempty"`
+	// LoadCPU is empty unless the container is swapped for a busy loop of LoadImage
+	LoadCPU   string `json:"loadCPU"`
+	LoadImage string `json:"loadImage"`
+	// Stress is unset unless the container is swapped for the stress script run by StressImage
+	Stress      *HelmStressValues `json:"stress,omitempty"`
+	StressImage string            `json:"stressImage"`
+}
+
+// HelmStressValues map onto inflater.StressOptions, quantities and durations are strings
+type HelmStressValues struct {
+	CPU         int    `json:"cpu"`
+	Memory      string `json:"memory"`
+	MemoryLimit string `json:"memoryLimit"`
+	Duration    string `json:"duration"`
+	RampUp      string `json:"rampUp"`
+	Leak        bool   `json:"leak"`
+}
+
+// HelmHPAValues map onto inflater.HPAOptions
+type HelmHPAValues struct {
+	MinReplicas          int32 `json:"minReplicas"`
+	MaxReplicas          int32 `json:"maxReplicas"`
+	TargetCPUUtilization int32 `json:"targetCPUUtilization"`
+}
+
+const chartYAML = `apiVersion: v2
+name: inflate
+description: Test deployments with various scheduling constraints exported by inflate
+type: application
+version: 0.1.0
+`
+
+const namespacesTemplate = `{{- $namespaces := dict }}
+{{- range .Values.inflates }}
+{{- $_ := set $namespaces .namespace true }}
+{{- end }}
+{{- range $namespace, $_ := $namespaces }}
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{ $namespace }}
+  labels:
+    managed-by: inflate
+{{- end }}
+`
+
+// inflatesTemplate mirrors inflater.GetPriorityClass, GetInflateDeployment, GetService and GetHorizontalPodAutoscaler.
+// Helm decodes numbers in values as float64, so integers go through int64 to not render 1000000 as 1e+06.
+const inflatesTemplate = `{{- range .Values.inflates }}
+{{- $inflate := . }}
+{{- $labels := dict "app" .name "managed-by" "inflate" }}
+{{- $priorityClassName := .priorityClassName }}
+{{- if and (not $priorityClassName) (hasKey . "priorityClassValue") }}
+{{- $priorityClassName = .name }}
+{{- end }}
+{{- if hasKey . "priorityClassValue" }}
+---
+apiVersion: scheduling.k8s.io/v1
+kind: PriorityClass
+metadata:
+  name: {{ $priorityClassName }}
+  labels:
+    app: {{ $priorityClassName }}
+    managed-by: inflate
+value: {{ int64 .priorityClassValue }}
+{{- with .preemptionPolicy }}
+preemptionPolicy: {{ . }}
+{{- end }}
+description: managed by inflate
+{{- end }}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ .name }}
+  namespace: {{ .namespace }}
+  labels:
+    {{- toYaml $labels | nindent 4 }}
+  {{- if .protect }}
+  annotations:
+    inflate/protect: "true"
+  {{- end }}
+spec:
+  {{- if not (hasKey . "hpa") }}
+  replicas: 1
+  {{- end }}
+  selector:
+    matchLabels:
+      {{- toYaml $labels | nindent 6 }}
+  template:
+    metadata:
+      labels:
+        {{- toYaml $labels | nindent 8 }}
+      {{- if .doNotDisrupt }}
+      annotations:
+        karpenter.sh/do-not-disrupt: "true"
+      {{- end }}
+    spec:
+      {{- if .hostNetwork }}
+      hostNetwork: true
+      {{- end }}
+      {{- with $priorityClassName }}
+      priorityClassName: {{ . }}
+      {{- end }}
+      terminationGracePeriodSeconds: 0
+      containers:
+      - name: {{ .name }}
+        {{- if .stress }}
+        image: {{ .stressImage }}
+        command:
+        - python3
+        args:
+        - -c
+        - {{ $.Files.Get "files/stress.py" | toJson }}
+        - --cpu={{ int64 .stress.cpu }}
+        {{- with .stress.memory }}
+        - --memory={{ . }}
+        {{- end }}
+        {{- with .stress.duration }}
+        - --duration={{ . }}
+        {{- end }}
+        {{- with .stress.rampUp }}
+        - --ramp={{ . }}
+        {{- end }}
+        {{- if .stress.leak }}
+        - --leak
+        {{- end }}
+        resources:
+          requests:
+            cpu: {{ if .stress.cpu }}{{ int64 .stress.cpu | quote }}{{ else }}"1"{{ end }}
+            memory: {{ .stress.memory | default "256" | quote }}
+          {{- with .stress.memoryLimit }}
+          limits:
+            memory: {{ . | quote }}
+          {{- end }}
+        {{- else if .loadCPU }}
+        image: {{ .loadImage }}
+        command:
+        - sh
+        - -c
+        - 'for i in $(seq $(nproc)); do while :; do :; done & done; wait'
+        resources:
+          requests:
+            cpu: {{ .loadCPU | quote }}
+            memory: "256"
+          limits:
+            cpu: {{ .loadCPU | quote }}
+        {{- else }}
+        image: {{ .image }}
+        resources:
+          requests:
+            cpu: "1"
+            memory: "256"
+        {{- end }}
+      {{- $nodeSelector := dict }}
+      {{- with .cpuArch }}{{ $_ := set $nodeSelector "kubernetes.io/arch" . }}{{ end }}
+      {{- with .os }}{{ $_ := set $nodeSelector "kubernetes.io/os" . }}{{ end }}
+      {{- with .nodePool }}{{ $_ := set $nodeSelector "karpenter.sh/nodepool" . }}{{ end }}
+      {{- with .capacityType }}{{ $_ := set $nodeSelector "karpenter.sh/capacity-type" . }}{{ end }}
+      {{- with .instanceType }}{{ $_ := set $nodeSelector "node.kubernetes.io/instance-type" . }}{{ end }}
+      {{- with .instanceFamily }}{{ $_ := set $nodeSelector "karpenter.k8s.aws/instance-family" . }}{{ end }}
+      {{- with $nodeSelector }}
+      nodeSelector:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- with .antiAffinity }}
+      affinity:
+        podAntiAffinity:
+          requiredDuringSchedulingIgnoredDuringExecution:
+          - labelSelector:
+              matchLabels:
+                {{- toYaml $labels | nindent 16 }}
+            topologyKey: {{ . }}
+      {{- end }}
+      {{- with .tolerations }}
+      tolerations:
+        {{- toYaml . | nindent 8 }}
+      {{- end }}
+      {{- $topologyKeys := list }}
+      {{- if .zonalSpread }}{{ $topologyKeys = append $topologyKeys "topology.kubernetes.io/zone" }}{{ end }}
+      {{- if .hostnameSpread }}{{ $topologyKeys = append $topologyKeys "kubernetes.io/hostname" }}{{ end }}
+      {{- if .capacityTypeSpread }}{{ $topologyKeys = append $topologyKeys "karpenter.sh/capacity-type" }}{{ end }}
+      {{- with $topologyKeys }}
+      topologySpreadConstraints:
+      {{- range . }}
+      - maxSkew: 1
+        topologyKey: {{ . }}
+        whenUnsatisfiable: DoNotSchedule
+        labelSelector:
+          matchLabels:
+            {{- toYaml $labels | nindent 12 }}
+      {{- end }}
+      {{- end }}
+{{- if .service }}
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: {{ .name }}
+  namespace: {{ .namespace }}
+  labels:
+    {{- toYaml $labels | nindent 4 }}
+spec:
+  selector:
+    app: {{ .name }}
+  ports:
+  - port: 8080
+    targetPort: 8080
+{{- end }}
+{{- with .hpa }}
+---
+apiVersion: autoscaling/v2
+kind: HorizontalPodAutoscaler
+metadata:
+  name: {{ $inflate.name }}
+  namespace: {{ $inflate.namespace }}
+  labels:
+    {{- toYaml $labels | nindent 4 }}
+spec:
+  scaleTargetRef:
+    apiVersion: apps/v1
+    kind: Deployment
+    name: {{ $inflate.name }}
+  minReplicas: {{ int64 .minReplicas }}
+  maxReplicas: {{ int64 .maxReplicas }}
+  metrics:
+  - type: Resource
+    resource:
+      name: cpu
+      target:
+        type: Utilization
+        averageUtilization: {{ int64 .targetCPUUtilization }}
+{{- end }}
+{{- end }}
+`
+
+// Helm writes a chart whose values describe each inflate with the same fields as inflater.Options
+func Helm(dir string, inflates []Inflate) ([]string, error) {
+	writer := &fileWriter{root: dir}
+	values := HelmValues{
+		Inflates: lo.Map(inflates, func(inflate Inflate, _ int) HelmInflateValues {
+			values := HelmInflateValues{
+				Name:               inflate.Collection.Deployment.Name,
+				Namespace:          inflate.Collection.Deployment.Namespace,
+				Image:              inflate.Options.Image,
+				ZonalSpread:        inflate.Options.ZonalSpread,
+				HostnameSpread:     inflate.Options.HostnameSpread,
+				CapacityTypeSpread: inflate.Options.CapacityTypeSpread,
+				AntiAffinity:       inflate.Options.AntiAffinity,
+				Tolerations:        inflate.Options.Tolerations,
+				HostNetwork:        inflate.Options.HostNetwork,
+				CPUArch:            inflate.Options.CPUArch,
+				OS:                 inflate.Options.OS,
+				NodePool:           inflate.Options.NodePool,
+				CapacityType:       inflate.Options.CapacityType,
+				InstanceType:       inflate.Options.InstanceType,
+				InstanceFamily:     inflate.Options.InstanceFamily,
+				DoNotDisrupt:       inflate.Options.DoNotDisrupt,
+				Service:            inflate.Collection.Service != nil,
+				PriorityClassName:  inflate.Options.PriorityClassName,
+				PriorityClassValue: inflate.Options.PriorityClassValue,
+				PreemptionPolicy:   inflate.Options.PreemptionPolicy,
+				Protect:            inflate.Options.Protect,
+			}
+			if hpa := inflate.Options.HPA; hpa != nil {
+				values.HPA = &HelmHPAValues{MinReplicas: hpa.MinReplicas, MaxReplicas: hpa.MaxReplicas, TargetCPUUtilization: hpa.TargetCPUUtilization}
+			}
+			if stress := inflate.Options.Stress; stress != nil {
+				values.Stress = &HelmStressValues{CPU: stress.CPU, Leak: stress.Leak}
+				if stress.Memory != nil {
+					values.Stress.Memory = stress.Memory.String()
+				}
+				if stress.MemoryLimit != nil {
+					values.Stress.MemoryLimit = stress.MemoryLimit.String()
+				}
+				if stress.Duration > 0 {
+					values.Stress.Duration = stress.Duration.String()
+				}
+				if stress.RampUp > 0 {
+					values.Stress.RampUp = stress.RampUp.String()
+				}
+				values.StressImage = lo.Ternary(inflate.Options.StressImage != "", inflate.Options.StressImage, inflater.DefaultStressImage)
+			}
+			if inflate.Options.LoadCPU != nil {
+				values.LoadCPU = inflate.Options.LoadCPU.String()
+				values.LoadImage = lo.Ternary(inflate.Options.LoadImage != "", inflate.Options.LoadImage, inflater.DefaultLoadImage)
+			}
+			return values
+		}),
+	}
+	if err := writer.write("Chart.yaml", chartYAML); err != nil {
+		return nil, err
+	}
+	if err := writer.writeYAML("values.yaml", values); err != nil {
+		return nil, err
+	}
+	if err := writer.write("templates/namespaces.yaml", namespacesTemplate); err != nil {
+		return nil, err
+	}
+	if err := writer.write("templates/inflates.yaml", inflatesTemplate); err != nil {
+		return nil, err
+	}
+	if err := writer.write("files/stress.py", inflater.StressScript); err != nil {
+		return nil, err
+	}
+	return writer.written, nil
+}
@@ -0,0 +1,242 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/samber/lo"
+	yamlv3 "gopkg.in/yaml.v3"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+// chartFiles is the .Files of a chart rendered by renderChart
+type chartFiles string
+
+func (f chartFiles) Get(name string) string {
+	contents, _ := os.ReadFile(filepath.Join(string(f), name))
+	return string(contents)
+}
+
+// renderChart renders the chart's templates like helm template does, with the values decoded by the same yaml library
+func renderChart(t *testing.T, dir string) []string {
+	t.Helper()
+	var values map[string]any
+	readYAML(t, filepath.Join(dir, "values.yaml"), &values)
+	funcs := sprig.TxtFuncMap()
+	funcs["toYaml"] = func(v any) string {
+		out, err := yaml.Marshal(v)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(string(out), "\n")
+	}
+	var documents []string
+	for _, name := range []string{"namespaces.yaml", "inflates.yaml"} {
+		contents, err := os.ReadFile(filepath.Join(dir, "templates", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tmpl, err := template.New(name).Funcs(funcs).Parse(string(contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var rendered strings.Builder
+		if err := tmpl.Execute(&rendered, map[string]any{"Values": values, "Files": chartFiles(dir)}); err != nil {
+			t.Fatal(err)
+		}
+		for _, document := range strings.Split(rendered.String(), "\n---\n") {
+			if strings.TrimSpace(document) != "" {
+				documents = append(documents, document)
+			}
+		}
+	}
+	return documents
+}
+
+// decodeRendered decodes a rendered document into obj, none of the objects have float fields
+// so a float like 1e+06 is an integer that was rendered from a float64 value
+func decodeRendered(document string, obj any) error {
+	var node yamlv3.Node
+	if err := yamlv3.Unmarshal([]byte(document), &node); err != nil {
+		return err
+	}
+	if err := rejectFloats(&node); err != nil {
+		return err
+	}
+	var fields map[string]any
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	contents, err := json.Marshal(fields)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(obj)
+}
+
+func rejectFloats(node *yamlv3.Node) error {
+	if node.Kind == yamlv3.ScalarNode && node.ShortTag() == "!!float" {
+		return fmt.Errorf("line %d: %s is rendered as a float", node.Line, node.Value)
+	}
+	for _, child := range node.Content {
+		if err := rejectFloats(child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestHelm renders the chart and compares its objects with what create renders for the same options
+func TestHelm(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options inflater.Options
+	}{
+		{
+			name:    "defaults",
+			options: inflater.Options{Name: "inflate", Namespace: "inflate", Image: inflater.DefaultImage},
+		},
+		{
+			name: "non-default options",
+			options: inflater.Options{
+				Name:               "web",
+				Namespace:          "team-a",
+				Image:              "nginx:1.25",
+				ZonalSpread:        true,
+				HostnameSpread:     true,
+				CapacityTypeSpread: true,
+				AntiAffinity:       "kubernetes.io/hostname",
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "batch", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "gpu", Operator: corev1.TolerationOpExists},
+				},
+				HostNetwork:        true,
+				CPUArch:            "arm64",
+				OS:                 "linux",
+				NodePool:           "default",
+				CapacityType:       "spot",
+				InstanceType:       "m7g.large",
+				InstanceFamily:     "m7g",
+				DoNotDisrupt:       true,
+				Protect:            true,
+				Service:            true,
+				PriorityClassValue: lo.ToPtr(int32(1000000)),
+				PreemptionPolicy:   "Never",
+				HPA:                &inflater.HPAOptions{MinReplicas: 2, MaxReplicas: 1000, TargetCPUUtilization: 50},
+				LoadCPU:            lo.ToPtr(resource.MustParse("500m")),
+			},
+		},
+		{
+			name: "stress",
+			options: inflater.Options{
+				Name:      "stress",
+				Namespace: "inflate",
+				Image:     inflater.DefaultImage,
+				Stress: &inflater.StressOptions{
+					CPU:         16,
+					Memory:      lo.ToPtr(resource.MustParse("1Gi")),
+					MemoryLimit: lo.ToPtr(resource.MustParse("2Gi")),
+					Duration:    10 * time.Minute,
+					RampUp:      time.Minute,
+					Leak:        true,
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			options := tc.options
+			options.DryRun = true
+			collection, err := inflater.New(nil).Inflate(ctx, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if _, err := Helm(dir, []Inflate{{Options: options, Collection: collection}}); err != nil {
+				t.Fatal(err)
+			}
+			rendered := renderChart(t, dir)
+
+			deployment, err := inflater.New(nil).GetInflateDeployment(ctx, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := []runtime.Object{inflater.New(nil).GetNamespace(options.Namespace)}
+			if options.PriorityClassValue != nil {
+				priorityClass, err := inflater.New(nil).GetPriorityClass(ctx, deployment.Spec.Template.Spec.PriorityClassName, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, priorityClass)
+			}
+			want = append(want, deployment)
+			if options.Service {
+				service, err := inflater.New(nil).GetService(ctx, deployment.Name, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, service)
+			}
+			if options.HPA != nil {
+				hpa, err := inflater.New(nil).GetHorizontalPodAutoscaler(ctx, deployment.Name, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+				want = append(want, hpa)
+			}
+			if len(rendered) != len(want) {
+				t.Fatalf("rendered %d objects, want %d", len(rendered), len(want))
+			}
+			for idx, obj := range want {
+				got := reflect.New(reflect.TypeOf(obj).Elem()).Interface().(runtime.Object)
+				if err := decodeRendered(rendered[idx], got); err != nil {
+					t.Fatalf("decoding %s, %v\n%s", reflect.TypeOf(obj).Elem().Name(), err, rendered[idx])
+				}
+				gotFields, err := inflater.NormalizedFields(got)
+				if err != nil {
+					t.Fatal(err)
+				}
+				wantFields, err := inflater.NormalizedFields(obj)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(gotFields, wantFields) {
+					gotYAML, _ := yaml.Marshal(gotFields)
+					wantYAML, _ := yaml.Marshal(wantFields)
+					t.Errorf("rendered %s =\n%s\nwant\n%s", reflect.TypeOf(obj).Elem().Name(), gotYAML, wantYAML)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+// baseName is the name of the objects in the kustomize base, overlays patch it to the inflate's name
+const baseName = "inflate"
+
+type kustomization struct {
+	APIVersion string   `json:"apiVersion"`
+	Kind       string   `json:"kind"`
+	Namespace  string   `json:"namespace,omitempty"`
+	Resources  []string `json:"resources,omitempty"`
+	Patches    []patch  `json:"patches,omitempty"`
+}
+
+type patch struct {
+	Path   string      `json:"path"`
+	Target patchTarget `json:"target"`
+}
+
+type patchTarget struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+type jsonPatchOperation struct {
+	Op    string `json:"op"`
+	Path  string `json:"path"`
+	Value any    `json:"value"`
+}
+
+// MarshalJSON writes the value of add and replace operations even when it is false, 0 or "", remove has no value
+func (o jsonPatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(struct {
+			Op   string `json:"op"`
+			Path string `json:"path"`
+		}{Op: o.Op, Path: o.Path})
+	}
+	type operation jsonPatchOperation
+	return json.Marshal(operation(o))
+}
+
+func newKustomization() kustomization {
+	return kustomization{
+		APIVersion: "kustomize.config.k8s.io/v1beta1",
+		Kind:       "Kustomization",
+	}
+}
+
+// Kustomize writes a kustomization with a base Deployment and Service and one overlay per inflate.
+// Each overlay JSON patches the base into the objects rendered by the inflater.
+func Kustomize(dir string, inflates []Inflate) ([]string, error) {
+	writer := &fileWriter{root: dir}
+	base, err := inflater.New(nil).Inflate(context.Background(), inflater.Options{
+		Name:      baseName,
+		Image:     inflater.DefaultImage,
+		Service:   true,
+		DryRun:    true,
+		Namespace: inflater.DefaultOptions.Namespace,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeKustomizeBase(writer, "base/deployment", "deployment.yaml", base.Deployment); err != nil {
+		return nil, err
+	}
+	if err := writeKustomizeBase(writer, "base/service", "service.yaml", base.Service); err != nil {
+		return nil, err
+	}
+
+	root := newKustomization()
+	root.Resources = append(root.Resources, "namespaces.yaml")
+	nsObjects := lo.Map(namespaces(inflates), func(namespace string, _ int) runtime.Object { return inflater.New(nil).GetNamespace(namespace) })
+	if err := writeObjects(writer, "namespaces.yaml", nsObjects...); err != nil {
+		return nil, err
+	}
+	for _, inflate := range inflates {
+		overlayDir := path.Join("overlays", fmt.Sprintf("%s-%s", inflate.Collection.Deployment.Namespace, inflate.Collection.Deployment.Name))
+		overlay := newKustomization()
+		overlay.Namespace = inflate.Collection.Deployment.Namespace
+		overlay.Resources = append(overlay.Resources, "../../base/deployment")
+		if err := writePatch(writer, &overlay, overlayDir, "deployment-patch.yaml", base.Deployment, inflate.Collection.Deployment); err != nil {
+			return nil, err
+		}
+		if inflate.Collection.Service != nil {
+			overlay.Resources = append(overlay.Resources, "../../base/service")
+			if err := writePatch(writer, &overlay, overlayDir, "service-patch.yaml", base.Service, inflate.Collection.Service); err != nil {
+				return nil, err
+			}
+		}
+		if inflate.Collection.PriorityClass != nil {
+			overlay.Resources = append(overlay.Resources, "priorityclass.yaml")
+			if err := writeObjects(writer, path.Join(overlayDir, "priorityclass.yaml"), inflate.Collection.PriorityClass); err != nil {
+				return nil, err
+			}
+		}
+		if inflate.Collection.HorizontalPodAutoscaler != nil {
+			overlay.Resources = append(overlay.Resources, "hpa.yaml")
+			if err := writeObjects(writer, path.Join(overlayDir, "hpa.yaml"), inflate.Collection.HorizontalPodAutoscaler); err != nil {
+				return nil, err
+			}
+		}
+		if err := writer.writeYAML(path.Join(overlayDir, "kustomization.yaml"), overlay); err != nil {
+			return nil, err
+		}
+		root.Resources = append(root.Resources, overlayDir)
+	}
+	if err := writer.writeYAML("kustomization.yaml", root); err != nil {
+		return nil, err
+	}
+	return writer.written, nil
+}
+
+func writeKustomizeBase(writer *fileWriter, dir string, fileName string, obj runtime.Object) error {
+	if err := writeObjects(writer, path.Join(dir, fileName), obj); err != nil {
+		return err
+	}
+	baseKustomization := newKustomization()
+	baseKustomization.Resources = []string{fileName}
+	return writer.writeYAML(path.Join(dir, "kustomization.yaml"), baseKustomization)
+}
+
+func writeObjects(writer *fileWriter, fileName string, objects ...runtime.Object) error {
+	var documents []any
+	for _, obj := range objects {
+		fields, err := inflater.NormalizedFields(obj)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, fields)
+	}
+	return writer.writeYAML(fileName, documents...)
+}
+
+// writePatch adds a JSON patch transforming base into desired to the overlay
+func writePatch(writer *fileWriter, overlay *kustomization, dir string, fileName string, base runtime.Object, desired runtime.Object) error {
+	baseFields, err := inflater.NormalizedFields(base)
+	if err != nil {
+		return err
+	}
+	desiredFields, err := inflater.NormalizedFields(desired)
+	if err != nil {
+		return err
+	}
+	// the overlay's namespace transformer sets the namespace
+	baseFields["metadata"].(map[string]any)["namespace"] = desiredFields["metadata"].(map[string]any)["namespace"]
+	operations := jsonPatch("", baseFields, desiredFields)
+	if len(operations) == 0 {
+		return nil
+	}
+	overlay.Patches = append(overlay.Patches, patch{
+		Path: fileName,
+		Target: patchTarget{
+			Kind: desired.GetObjectKind().GroupVersionKind().Kind,
+			Name: baseName,
+		},
+	})
+	return writer.writeYAML(path.Join(dir, fileName), operations)
+}
+
+// jsonPatch returns the RFC 6902 operations transforming before into after.
+// Lists of different lengths are replaced as a whole.
+func jsonPatch(pointer string, before, after any) []jsonPatchOperation {
+	beforeMap, beforeIsMap := before.(map[string]any)
+	afterMap, afterIsMap := after.(map[string]any)
+	if beforeIsMap && afterIsMap {
+		keys := lo.Uniq(append(lo.Keys(beforeMap), lo.Keys(afterMap)...))
+		sort.Strings(keys)
+		var operations []jsonPatchOperation
+		for _, key := range keys {
+			keyPointer := pointer + "/" + escapePointer(key)
+			beforeValue, inBefore := beforeMap[key]
+			afterValue, inAfter := afterMap[key]
+			switch {
+			case !inBefore:
+				operations = append(operations, jsonPatchOperation{Op: "add", Path: keyPointer, Value: afterValue})
+			case !inAfter:
+				operations = append(operations, jsonPatchOperation{Op: "remove", Path: keyPointer})
+			default:
+				operations = append(operations, jsonPatch(keyPointer, beforeValue, afterValue)...)
+			}
+		}
+		return operations
+	}
+	beforeSlice, beforeIsSlice := before.([]any)
+	afterSlice, afterIsSlice := after.([]any)
+	if beforeIsSlice && afterIsSlice && len(beforeSlice) == len(afterSlice) {
+		var operations []jsonPatchOperation
+		for idx := range beforeSlice {
+			operations = append(operations, jsonPatch(fmt.Sprintf("%s/%d", pointer, idx), beforeSlice[idx], afterSlice[idx])...)
+		}
+		return operations
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []jsonPatchOperation{{Op: "replace", Path: pointer, Value: after}}
+}
+
+func escapePointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
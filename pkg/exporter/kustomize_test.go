@@ -0,0 +1,258 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package exporter
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	jsonpatch "github.com/evanphx/json-patch"
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+
+	"github.com/bwagner5/inflate/pkg/inflater"
+)
+
+func TestJSONPatch(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		before any
+		after  any
+		want   []jsonPatchOperation
+	}{
+		{name: "equal", before: map[string]any{"a": "x"}, after: map[string]any{"a": "x"}},
+		{
+			name:   "added removed and replaced keys",
+			before: map[string]any{"a": "x", "b": "y"},
+			after:  map[string]any{"b": "z", "c": false},
+			want: []jsonPatchOperation{
+				{Op: "remove", Path: "/a"},
+				{Op: "replace", Path: "/b", Value: "z"},
+				{Op: "add", Path: "/c", Value: false},
+			},
+		},
+		{
+			name:   "keys are escaped",
+			before: map[string]any{},
+			after:  map[string]any{"karpenter.sh/do-not-disrupt": "true", "a~b": "c"},
+			want: []jsonPatchOperation{
+				{Op: "add", Path: "/a~0b", Value: "c"},
+				{Op: "add", Path: "/karpenter.sh~1do-not-disrupt", Value: "true"},
+			},
+		},
+		{
+			name:   "lists of the same length are patched by index",
+			before: map[string]any{"args": []any{"a", "b"}},
+			after:  map[string]any{"args": []any{"a", "c"}},
+			want:   []jsonPatchOperation{{Op: "replace", Path: "/args/1", Value: "c"}},
+		},
+		{
+			name:   "lists of different lengths are replaced",
+			before: map[string]any{"args": []any{"a"}},
+			after:  map[string]any{"args": []any{"a", "b"}},
+			want:   []jsonPatchOperation{{Op: "replace", Path: "/args", Value: []any{"a", "b"}}},
+		},
+		{
+			name:   "zero values are replaced",
+			before: map[string]any{"replicas": float64(1)},
+			after:  map[string]any{"replicas": float64(0)},
+			want:   []jsonPatchOperation{{Op: "replace", Path: "/replicas", Value: float64(0)}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := jsonPatch("", tc.before, tc.after); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("jsonPatch() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJSONPatchOperationMarshalJSON(t *testing.T) {
+	for _, tc := range []struct {
+		operation jsonPatchOperation
+		want      string
+	}{
+		{operation: jsonPatchOperation{Op: "add", Path: "/a", Value: false}, want: `{"op":"add","path":"/a","value":false}`},
+		{operation: jsonPatchOperation{Op: "replace", Path: "/a", Value: 0}, want: `{"op":"replace","path":"/a","value":0}`},
+		{operation: jsonPatchOperation{Op: "replace", Path: "/a", Value: ""}, want: `{"op":"replace","path":"/a","value":""}`},
+		{operation: jsonPatchOperation{Op: "remove", Path: "/a"}, want: `{"op":"remove","path":"/a"}`},
+	} {
+		t.Run(tc.want, func(t *testing.T) {
+			got, err := json.Marshal(tc.operation)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tc.want {
+				t.Errorf("json.Marshal() = %s, want %s", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestKustomizeOverlays applies each overlay's patches to the base and compares the result with what create renders
+func TestKustomizeOverlays(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options inflater.Options
+	}{
+		{
+			name:    "defaults",
+			options: inflater.Options{Name: "inflate", Namespace: "inflate", Image: inflater.DefaultImage},
+		},
+		{
+			name: "non-default options",
+			options: inflater.Options{
+				Name:               "web",
+				Namespace:          "team-a",
+				Image:              "nginx:1.25",
+				ZonalSpread:        true,
+				HostnameSpread:     true,
+				CapacityTypeSpread: true,
+				AntiAffinity:       "kubernetes.io/hostname",
+				Tolerations: []corev1.Toleration{
+					{Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "batch", Effect: corev1.TaintEffectNoSchedule},
+					{Key: "gpu", Operator: corev1.TolerationOpExists},
+				},
+				HostNetwork:        true,
+				CPUArch:            "arm64",
+				OS:                 "linux",
+				NodePool:           "default",
+				CapacityType:       "spot",
+				InstanceFamily:     "m7g",
+				DoNotDisrupt:       true,
+				Protect:            true,
+				Service:            true,
+				PriorityClassValue: lo.ToPtr(int32(1000000)),
+				PreemptionPolicy:   "Never",
+				HPA:                &inflater.HPAOptions{MinReplicas: 2, MaxReplicas: 10, TargetCPUUtilization: 50},
+				LoadCPU:            lo.ToPtr(resource.MustParse("500m")),
+			},
+		},
+		{
+			name: "stress without a service",
+			options: inflater.Options{
+				Name:      "stress",
+				Namespace: "inflate",
+				Image:     inflater.DefaultImage,
+				Stress:    &inflater.StressOptions{CPU: 2, Memory: lo.ToPtr(resource.MustParse("1Gi"))},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			options := tc.options
+			options.DryRun = true
+			collection, err := inflater.New(nil).Inflate(ctx, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			dir := t.TempDir()
+			if _, err := Kustomize(dir, []Inflate{{Options: options, Collection: collection}}); err != nil {
+				t.Fatal(err)
+			}
+			overlayDir := filepath.Join(dir, "overlays", options.Namespace+"-"+collection.Deployment.Name)
+
+			deployment, err := inflater.New(nil).GetInflateDeployment(ctx, options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertOverlay(t, filepath.Join(dir, "base", "deployment", "deployment.yaml"), overlayDir, "deployment-patch.yaml", deployment)
+			if options.Service {
+				service, err := inflater.New(nil).GetService(ctx, deployment.Name, options)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assertOverlay(t, filepath.Join(dir, "base", "service", "service.yaml"), overlayDir, "service-patch.yaml", service)
+			}
+		})
+	}
+}
+
+// assertOverlay applies the overlay's namespace and patch to the base object and compares the result with want
+func assertOverlay(t *testing.T, basePath string, overlayDir string, patchFile string, want runtime.Object) {
+	t.Helper()
+	var overlay kustomization
+	readYAML(t, filepath.Join(overlayDir, "kustomization.yaml"), &overlay)
+	var base map[string]any
+	readYAML(t, basePath, &base)
+	base["metadata"].(map[string]any)["namespace"] = overlay.Namespace
+	patched, err := json.Marshal(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lo.ContainsBy(overlay.Patches, func(p patch) bool { return p.Path == patchFile }) {
+		var operations []any
+		readYAML(t, filepath.Join(overlayDir, patchFile), &operations)
+		operationsJSON, err := json.Marshal(operations)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded, err := jsonpatch.DecodePatch(operationsJSON)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if patched, err = decoded.Apply(patched); err != nil {
+			t.Fatalf("applying %s, %v", patchFile, err)
+		}
+	} else if _, err := os.Stat(filepath.Join(overlayDir, patchFile)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("%s is written but not in the overlay's patches", patchFile)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(patched, &got); err != nil {
+		t.Fatal(err)
+	}
+	wantFields, err := inflater.NormalizedFields(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, roundTrip(t, wantFields)) {
+		gotYAML, _ := yaml.Marshal(got)
+		wantYAML, _ := yaml.Marshal(wantFields)
+		t.Errorf("patched %s =\n%s\nwant\n%s", patchFile, gotYAML, wantYAML)
+	}
+}
+
+func readYAML(t *testing.T, path string, into any) {
+	t.Helper()
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := yaml.Unmarshal(contents, into); err != nil {
+		t.Fatalf("parsing %s, %v", path, err)
+	}
+}
+
+// roundTrip converts fields to the types json.Unmarshal produces
+func roundTrip(t *testing.T, fields map[string]any) map[string]any {
+	t.Helper()
+	contents, err := json.Marshal(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(contents, &out); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
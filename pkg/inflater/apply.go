@@ -0,0 +1,218 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// FieldManager is the server-side apply field manager used for all inflate objects
+	FieldManager = "inflate"
+
+	OperationCreated    = "created"
+	OperationConfigured = "configured"
+	OperationUnchanged  = "unchanged"
+)
+
+// ApplyResult describes what a server-side apply did to a single object
+type ApplyResult struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Operation string
+	Changes   []FieldChange
+}
+
+// FieldChange is a single field that was modified by re-applying an existing object
+type FieldChange struct {
+	Path   string
+	Before string
+	After  string
+}
+
+type object interface {
+	metav1.Object
+	runtime.Object
+}
+
+// applyClient is satisfied by the typed client-go clients, e.g. DeploymentInterface
+type applyClient[T object] interface {
+	Get(ctx context.Context, name string, opts metav1.GetOptions) (T, error)
+	Patch(ctx context.Context, name string, pt types.PatchType, data []byte, opts metav1.PatchOptions, subresources ...string) (T, error)
+}
+
+// apply server-side applies obj and reports which fields changed if the object already existed
+func apply[T object](ctx context.Context, logger logr.Logger, client applyClient[T], obj T, force bool) (T, ApplyResult, error) {
+	var applied T
+	result := ApplyResult{
+		Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+	var live T
+	err := withRetry(logger, func() (err error) {
+		live, err = client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		return err
+	})
+	if err != nil && !errors.IsNotFound(err) {
+		return applied, result, err
+	}
+	existed := err == nil
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return applied, result, err
+	}
+	err = withRetry(logger, func() (err error) {
+		applied, err = client.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+			FieldManager: FieldManager,
+			Force:        &force,
+		})
+		return err
+	})
+	if err != nil {
+		return applied, result, err
+	}
+	// typed clients drop the TypeMeta of decoded objects
+	applied.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	if !existed {
+		result.Operation = OperationCreated
+		logger.V(1).Info("applied", "kind", result.Kind, "namespace", result.Namespace, "name", result.Name, "operation", result.Operation)
+		return applied, result, nil
+	}
+	result.Changes, err = fieldChanges(live, applied)
+	if err != nil {
+		return applied, result, err
+	}
+	if len(result.Changes) == 0 {
+		result.Operation = OperationUnchanged
+	} else {
+		result.Operation = OperationConfigured
+	}
+	logger.V(1).Info("applied", "kind", result.Kind, "namespace", result.Namespace, "name", result.Name, "operation", result.Operation)
+	return applied, result, nil
+}
+
+// fieldChanges returns the user-facing fields that differ between before and after,
+// ignoring status and server-populated metadata
+func fieldChanges(before, after runtime.Object) ([]FieldChange, error) {
+	beforeFields, err := comparableFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := comparableFields(after)
+	if err != nil {
+		return nil, err
+	}
+	return diffFields("", beforeFields, afterFields), nil
+}
+
+func comparableFields(obj runtime.Object) (map[string]any, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "apiVersion")
+	delete(fields, "kind")
+	delete(fields, "status")
+	metadata, _ := fields["metadata"].(map[string]any)
+	annotations, _ := metadata["annotations"].(map[string]any)
+	// bumped by the deployment controller, not by inflate
+	delete(annotations, "deployment.kubernetes.io/revision")
+	fields["metadata"] = map[string]any{
+		"labels":      metadata["labels"],
+		"annotations": annotations,
+	}
+	return fields, nil
+}
+
+func diffFields(path string, before, after any) []FieldChange {
+	beforeMap, beforeIsMap := before.(map[string]any)
+	afterMap, afterIsMap := after.(map[string]any)
+	if (beforeIsMap || before == nil) && (afterIsMap || after == nil) && (beforeIsMap || afterIsMap) {
+		keys := map[string]struct{}{}
+		for key := range beforeMap {
+			keys[key] = struct{}{}
+		}
+		for key := range afterMap {
+			keys[key] = struct{}{}
+		}
+		sortedKeys := make([]string, 0, len(keys))
+		for key := range keys {
+			sortedKeys = append(sortedKeys, key)
+		}
+		sort.Strings(sortedKeys)
+		var changes []FieldChange
+		for _, key := range sortedKeys {
+			changes = append(changes, diffFields(joinPath(path, key), beforeMap[key], afterMap[key])...)
+		}
+		return changes
+	}
+	beforeSlice, beforeIsSlice := before.([]any)
+	afterSlice, afterIsSlice := after.([]any)
+	if (beforeIsSlice || before == nil) && (afterIsSlice || after == nil) && (beforeIsSlice || afterIsSlice) {
+		length := len(beforeSlice)
+		if len(afterSlice) > length {
+			length = len(afterSlice)
+		}
+		var changes []FieldChange
+		for idx := 0; idx < length; idx++ {
+			var beforeElem, afterElem any
+			if idx < len(beforeSlice) {
+				beforeElem = beforeSlice[idx]
+			}
+			if idx < len(afterSlice) {
+				afterElem = afterSlice[idx]
+			}
+			changes = append(changes, diffFields(fmt.Sprintf("%s[%d]", path, idx), beforeElem, afterElem)...)
+		}
+		return changes
+	}
+	if reflect.DeepEqual(before, after) {
+		return nil
+	}
+	return []FieldChange{{Path: path, Before: formatField(before), After: formatField(after)}}
+}
+
+func joinPath(path string, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+func formatField(value any) string {
+	if value == nil {
+		return "<none>"
+	}
+	if str, ok := value.(string); ok {
+		return str
+	}
+	out, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(out)
+}
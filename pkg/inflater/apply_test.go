@@ -0,0 +1,224 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestDiffFields(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		before any
+		after  any
+		want   []FieldChange
+	}{
+		{
+			name:   "equal",
+			before: map[string]any{"a": "x", "b": []any{int64(1)}},
+			after:  map[string]any{"a": "x", "b": []any{int64(1)}},
+		},
+		{
+			name:   "changed scalar",
+			before: map[string]any{"spec": map[string]any{"replicas": int64(1)}},
+			after:  map[string]any{"spec": map[string]any{"replicas": int64(3)}},
+			want:   []FieldChange{{Path: "spec.replicas", Before: "1", After: "3"}},
+		},
+		{
+			name:   "added and removed keys in sorted order",
+			before: map[string]any{"b": "gone"},
+			after:  map[string]any{"a": "new"},
+			want: []FieldChange{
+				{Path: "a", Before: "<none>", After: "new"},
+				{Path: "b", Before: "gone", After: "<none>"},
+			},
+		},
+		{
+			name:   "list elements",
+			before: map[string]any{"args": []any{"a"}},
+			after:  map[string]any{"args": []any{"b", "c"}},
+			want: []FieldChange{
+				{Path: "args[0]", Before: "a", After: "b"},
+				{Path: "args[1]", Before: "<none>", After: "c"},
+			},
+		},
+		{
+			name:   "nested map added",
+			before: map[string]any{},
+			after:  map[string]any{"labels": map[string]any{"app": "x"}},
+			want:   []FieldChange{{Path: "labels.app", Before: "<none>", After: "x"}},
+		},
+		{
+			name:   "type change is formatted as json",
+			before: map[string]any{"value": "1"},
+			after:  map[string]any{"value": map[string]any{"a": true}},
+			want:   []FieldChange{{Path: "value", Before: "1", After: `{"a":true}`}},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := diffFields("", tc.before, tc.after); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("diffFields() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFieldChanges(t *testing.T) {
+	before := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "inflate",
+			ResourceVersion: "1",
+			Annotations:     map[string]string{"deployment.kubernetes.io/revision": "1"},
+		},
+		Spec:   appsv1.DeploymentSpec{Replicas: lo.ToPtr(int32(1))},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	after := before.DeepCopy()
+	after.ResourceVersion = "2"
+	after.Annotations["deployment.kubernetes.io/revision"] = "2"
+	after.Status.ReadyReplicas = 0
+	changes, err := fieldChanges(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) != 0 {
+		t.Errorf("server-populated fields should be ignored, got %+v", changes)
+	}
+
+	after.Spec.Replicas = lo.ToPtr(int32(2))
+	changes, err = fieldChanges(before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []FieldChange{{Path: "spec.replicas", Before: "1", After: "2"}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("fieldChanges() = %+v, want %+v", changes, want)
+	}
+}
+
+// fakeApplyClient fails the first calls of Get and Patch with the errors in order, then gets live and patches into the applied object
+type fakeApplyClient struct {
+	live      *corev1.ConfigMap
+	getErrs   []error
+	patchErrs []error
+	gets      int
+	patches   int
+}
+
+func (c *fakeApplyClient) Get(_ context.Context, name string, _ metav1.GetOptions) (*corev1.ConfigMap, error) {
+	c.gets++
+	if len(c.getErrs) > 0 {
+		err := c.getErrs[0]
+		c.getErrs = c.getErrs[1:]
+		return nil, err
+	}
+	if c.live == nil {
+		return nil, apierrors.NewNotFound(corev1.Resource("configmaps"), name)
+	}
+	return c.live.DeepCopy(), nil
+}
+
+func (c *fakeApplyClient) Patch(_ context.Context, _ string, _ types.PatchType, data []byte, _ metav1.PatchOptions, _ ...string) (*corev1.ConfigMap, error) {
+	c.patches++
+	if len(c.patchErrs) > 0 {
+		err := c.patchErrs[0]
+		c.patchErrs = c.patchErrs[1:]
+		return nil, err
+	}
+	applied := &corev1.ConfigMap{}
+	return applied, json.Unmarshal(data, applied)
+}
+
+func TestApplyRetries(t *testing.T) {
+	defer func(backoff wait.Backoff) { RetryBackoff = backoff }(RetryBackoff)
+	RetryBackoff = wait.Backoff{Steps: 3, Duration: time.Millisecond}
+	throttled := apierrors.NewTooManyRequests("slow down", 0)
+	unavailable := apierrors.NewServiceUnavailable("unavailable")
+	forbidden := apierrors.NewForbidden(corev1.Resource("configmaps"), "inflate", errors.New("denied"))
+	obj := &corev1.ConfigMap{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: "inflate"},
+		Data:       map[string]string{"a": "b"},
+	}
+	for _, tc := range []struct {
+		name          string
+		client        *fakeApplyClient
+		wantOperation string
+		wantErr       error
+		wantGets      int
+		wantPatches   int
+	}{
+		{
+			name:          "throttled get of a new object",
+			client:        &fakeApplyClient{getErrs: []error{throttled}},
+			wantOperation: OperationCreated,
+			wantGets:      2,
+			wantPatches:   1,
+		},
+		{
+			name:          "unavailable get of an existing object",
+			client:        &fakeApplyClient{live: obj, getErrs: []error{unavailable, throttled}},
+			wantOperation: OperationUnchanged,
+			wantGets:      3,
+			wantPatches:   1,
+		},
+		{
+			name:          "throttled patch",
+			client:        &fakeApplyClient{patchErrs: []error{throttled}},
+			wantOperation: OperationCreated,
+			wantGets:      1,
+			wantPatches:   2,
+		},
+		{
+			name:     "get gives up after the backoff steps",
+			client:   &fakeApplyClient{getErrs: []error{throttled, throttled, throttled}},
+			wantErr:  throttled,
+			wantGets: 3,
+		},
+		{
+			name:     "get errors that are not transient are not retried",
+			client:   &fakeApplyClient{getErrs: []error{forbidden}},
+			wantErr:  forbidden,
+			wantGets: 1,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, result, err := apply[*corev1.ConfigMap](context.Background(), logr.Discard(), tc.client, obj, false)
+			if !errors.Is(err, tc.wantErr) {
+				t.Errorf("apply() error = %v, want %v", err, tc.wantErr)
+			}
+			if result.Operation != tc.wantOperation {
+				t.Errorf("apply() operation = %q, want %q", result.Operation, tc.wantOperation)
+			}
+			if tc.client.gets != tc.wantGets || tc.client.patches != tc.wantPatches {
+				t.Errorf("apply() made %d gets and %d patches, want %d and %d", tc.client.gets, tc.client.patches, tc.wantGets, tc.wantPatches)
+			}
+		})
+	}
+}
@@ -0,0 +1,302 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	ChurnModeDelete  = "delete"
+	ChurnModeRollout = "rollout"
+
+	// AnnotationChurnedAt is bumped on the pod template to roll the deployment
+	AnnotationChurnedAt = "inflate/churned-at"
+)
+
+// ChurnOptions configure how an inflate's pods are turned over
+type ChurnOptions struct {
+	Namespace string
+	Name      string
+	Mode      string
+	// Interval is the time between disruptions
+	Interval time.Duration
+	// Duration is how long to keep disrupting
+	Duration time.Duration
+	// SettleTimeout is how long to wait for outstanding replacements to become ready after the last disruption
+	SettleTimeout time.Duration
+}
+
+// ChurnResult summarizes the replacement pods of a churn run
+type ChurnResult struct {
+	Namespace    string
+	Name         string
+	Mode         string
+	Disruptions  int
+	DeletedPods  int
+	Replacements int
+	// Ready is the number of replacements that became ready, the latencies are measured for them
+	Ready int
+	// MovedNodes is the number of ready replacements that landed on a node other than the one of the pod they replaced
+	MovedNodes int
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+	LatencyMax time.Duration
+}
+
+// disruption is waiting for replacements of the pods it deleted
+type disruption struct {
+	time     time.Time
+	nodes    []string
+	expected int
+}
+
+type replacement struct {
+	disruption *disruption
+	ready      bool
+}
+
+type churnTracker struct {
+	mu           sync.Mutex
+	seen         map[types.UID]struct{}
+	pending      []*disruption
+	replacements map[types.UID]*replacement
+	result       ChurnResult
+	latencies    []time.Duration
+}
+
+func newChurnTracker(opts ChurnOptions) *churnTracker {
+	return &churnTracker{
+		seen:         map[types.UID]struct{}{},
+		replacements: map[types.UID]*replacement{},
+		result:       ChurnResult{Namespace: opts.Namespace, Name: opts.Name, Mode: opts.Mode},
+	}
+}
+
+// Churn continuously deletes random pods of an inflate, or rolls its deployment, and measures
+// how long replacements take to become ready and how often they land on a different node
+func (i Inflater) Churn(ctx context.Context, opts ChurnOptions) (ChurnResult, error) {
+	tracker := newChurnTracker(opts)
+	if _, err := i.clientset.AppsV1().Deployments(opts.Namespace).Get(ctx, opts.Name, metav1.GetOptions{}); errors.IsNotFound(err) {
+		return tracker.result, &NotFoundError{Namespace: opts.Namespace, Name: opts.Name}
+	} else if err != nil {
+		return tracker.result, err
+	}
+	informerCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	factory := informers.NewSharedInformerFactoryWithOptions(i.clientset, 0,
+		informers.WithNamespace(opts.Namespace),
+		informers.WithTweakListOptions(func(listOpts *metav1.ListOptions) {
+			listOpts.LabelSelector = fmt.Sprintf("app=%s,managed-by=inflate", opts.Name)
+		}),
+	)
+	podInformer := factory.Core().V1().Pods()
+	if _, err := podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { tracker.observe(obj) },
+		UpdateFunc: func(_, obj any) { tracker.observe(obj) },
+		DeleteFunc: func(obj any) { tracker.deleted(obj) },
+	}); err != nil {
+		return tracker.result, err
+	}
+	factory.Start(informerCtx.Done())
+	factory.WaitForCacheSync(informerCtx.Done())
+
+	deadline := time.Now().Add(opts.Duration)
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for time.Now().Before(deadline) {
+		pods, err := podInformer.Lister().Pods(opts.Namespace).List(labels.Everything())
+		if err != nil {
+			return tracker.result, err
+		}
+		if err := i.disrupt(ctx, opts, tracker, pods); err != nil {
+			return tracker.summarize(), err
+		}
+		select {
+		case <-ctx.Done():
+			return tracker.summarize(), ctx.Err()
+		case <-ticker.C:
+		}
+	}
+	settleCtx, settleCancel := context.WithTimeout(ctx, opts.SettleTimeout)
+	defer settleCancel()
+	for !tracker.settled() {
+		select {
+		case <-settleCtx.Done():
+			return tracker.summarize(), &TimeoutError{Operation: "waiting for the replacements to be ready", Timeout: opts.SettleTimeout}
+		case <-time.After(time.Second):
+		}
+	}
+	return tracker.summarize(), nil
+}
+
+func (i Inflater) disrupt(ctx context.Context, opts ChurnOptions, tracker *churnTracker, pods []*corev1.Pod) error {
+	running := lo.Filter(pods, func(pod *corev1.Pod, _ int) bool {
+		return pod.DeletionTimestamp == nil && pod.Status.Phase == corev1.PodRunning
+	})
+	switch opts.Mode {
+	case ChurnModeRollout:
+		tracker.disrupted(running)
+		i.logger.V(1).Info("rolling deployment", "namespace", opts.Namespace, "name", opts.Name)
+		patch, err := json.Marshal(map[string]any{"spec": map[string]any{"template": map[string]any{"metadata": map[string]any{
+			"annotations": map[string]string{AnnotationChurnedAt: time.Now().UTC().Format(time.RFC3339Nano)},
+		}}}})
+		if err != nil {
+			return err
+		}
+		return withRetry(i.logger, func() error {
+			_, err := i.clientset.AppsV1().Deployments(opts.Namespace).Patch(ctx, opts.Name, types.StrategicMergePatchType, patch, metav1.PatchOptions{
+				FieldManager: FieldManager + "-churn",
+			})
+			return err
+		})
+	default:
+		if len(running) == 0 {
+			return nil
+		}
+		//nolint:gosec
+		pod := running[rand.Intn(len(running))]
+		tracker.disrupted([]*corev1.Pod{pod})
+		i.logger.V(1).Info("deleting pod", "namespace", pod.Namespace, "name", pod.Name, "node", pod.Spec.NodeName)
+		return withRetry(i.logger, func() error {
+			return i.clientset.CoreV1().Pods(opts.Namespace).Delete(ctx, pod.Name, metav1.DeleteOptions{})
+		})
+	}
+}
+
+// disrupted records that pods are about to be replaced, pods created from now on are their replacements
+func (t *churnTracker) disrupted(pods []*corev1.Pod) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.result.Disruptions++
+	if len(pods) == 0 {
+		return
+	}
+	t.result.DeletedPods += len(pods)
+	t.pending = append(t.pending, &disruption{
+		time:     time.Now(),
+		nodes:    lo.Map(pods, func(pod *corev1.Pod, _ int) string { return pod.Spec.NodeName }),
+		expected: len(pods),
+	})
+}
+
+func (t *churnTracker) observe(obj any) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.seen[pod.UID]; !ok {
+		t.seen[pod.UID] = struct{}{}
+		// replacements are matched to disruptions in the order they happened
+		if len(t.pending) > 0 && pod.CreationTimestamp.Time.After(t.pending[0].time.Add(-time.Second)) {
+			t.replacements[pod.UID] = &replacement{disruption: t.pending[0]}
+			t.result.Replacements++
+			if t.pending[0].expected--; t.pending[0].expected == 0 {
+				t.pending = t.pending[1:]
+			}
+		}
+	}
+	replaced, ok := t.replacements[pod.UID]
+	if !ok || replaced.ready || !isReady(pod) {
+		return
+	}
+	replaced.ready = true
+	t.result.Ready++
+	t.latencies = append(t.latencies, time.Since(replaced.disruption.time))
+	if !lo.Contains(replaced.disruption.nodes, pod.Spec.NodeName) {
+		t.result.MovedNodes++
+	}
+}
+
+// deleted gives the disruption of a replacement that was deleted before it became ready back the replacement,
+// so that the pod created in its place is counted instead
+func (t *churnTracker) deleted(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	replaced, ok := t.replacements[pod.UID]
+	if !ok || replaced.ready {
+		return
+	}
+	delete(t.replacements, pod.UID)
+	t.result.Replacements--
+	if replaced.disruption.expected++; replaced.disruption.expected > 1 {
+		return
+	}
+	// the disruption was no longer pending, it goes back in the order the disruptions happened
+	idx := sort.Search(len(t.pending), func(idx int) bool { return t.pending[idx].time.After(replaced.disruption.time) })
+	t.pending = append(t.pending[:idx], append([]*disruption{replaced.disruption}, t.pending[idx:]...)...)
+}
+
+func (t *churnTracker) settled() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.pending) == 0 && lo.EveryBy(lo.Values(t.replacements), func(r *replacement) bool { return r.ready })
+}
+
+func (t *churnTracker) summarize() ChurnResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result := t.result
+	latencies := append([]time.Duration{}, t.latencies...)
+	sort.Slice(latencies, func(a, b int) bool { return latencies[a] < latencies[b] })
+	if len(latencies) > 0 {
+		result.LatencyP50 = percentile(latencies, 0.5)
+		result.LatencyP90 = percentile(latencies, 0.9)
+		result.LatencyMax = latencies[len(latencies)-1]
+	}
+	return result
+}
+
+// percentile of sorted durations using the nearest rank
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(float64(len(sorted))*p+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func isReady(pod *corev1.Pod) bool {
+	return lo.ContainsBy(pod.Status.Conditions, func(condition corev1.PodCondition) bool {
+		return condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue
+	})
+}
@@ -0,0 +1,161 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestPercentile(t *testing.T) {
+	seconds := func(values ...int) []time.Duration {
+		var durations []time.Duration
+		for _, value := range values {
+			durations = append(durations, time.Duration(value)*time.Second)
+		}
+		return durations
+	}
+	for _, tc := range []struct {
+		name   string
+		sorted []time.Duration
+		p      float64
+		want   time.Duration
+	}{
+		{name: "single", sorted: seconds(3), p: 0.5, want: 3 * time.Second},
+		{name: "p50 of two", sorted: seconds(1, 2), p: 0.5, want: time.Second},
+		{name: "p50 of odd", sorted: seconds(1, 2, 3), p: 0.5, want: 2 * time.Second},
+		{name: "p90 of ten", sorted: seconds(1, 2, 3, 4, 5, 6, 7, 8, 9, 10), p: 0.9, want: 9 * time.Second},
+		{name: "p90 of three", sorted: seconds(1, 2, 3), p: 0.9, want: 3 * time.Second},
+		{name: "p0 clamps to the first", sorted: seconds(1, 2, 3), p: 0, want: time.Second},
+		{name: "p100", sorted: seconds(1, 2, 3), p: 1, want: 3 * time.Second},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := percentile(tc.sorted, tc.p); got != tc.want {
+				t.Errorf("percentile(%v, %v) = %s, want %s", tc.sorted, tc.p, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChurnTracker(t *testing.T) {
+	// pod is a running pod on the node created at the time, ready when ready is true
+	pod := func(uid string, node string, created time.Time, ready bool) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: uid, UID: types.UID(uid), CreationTimestamp: metav1.NewTime(created)},
+			Spec:       corev1.PodSpec{NodeName: node},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, Conditions: []corev1.PodCondition{
+				{Type: corev1.PodReady, Status: lo.Ternary(ready, corev1.ConditionTrue, corev1.ConditionFalse)},
+			}},
+		}
+	}
+	before := time.Now().Add(-time.Hour)
+	for _, tc := range []struct {
+		name        string
+		steps       func(tracker *churnTracker)
+		want        ChurnResult
+		wantSettled bool
+	}{
+		{
+			name:        "disruption without pods",
+			steps:       func(tracker *churnTracker) { tracker.disrupted(nil) },
+			want:        ChurnResult{Disruptions: 1},
+			wantSettled: true,
+		},
+		{
+			name: "replacement is pending until it is ready",
+			steps: func(tracker *churnTracker) {
+				tracker.observe(pod("old", "n1", before, true))
+				tracker.disrupted([]*corev1.Pod{pod("old", "n1", before, true)})
+				tracker.observe(pod("other", "n1", before, true))
+				tracker.observe(pod("new", "n2", time.Now(), false))
+			},
+			want: ChurnResult{Disruptions: 1, DeletedPods: 1, Replacements: 1},
+		},
+		{
+			name: "ready replacement on another node",
+			steps: func(tracker *churnTracker) {
+				tracker.disrupted([]*corev1.Pod{pod("old", "n1", before, true)})
+				tracker.observe(pod("new", "n2", time.Now(), false))
+				tracker.observe(pod("new", "n2", time.Now(), true))
+				tracker.observe(pod("new", "n2", time.Now(), true))
+			},
+			want:        ChurnResult{Disruptions: 1, DeletedPods: 1, Replacements: 1, Ready: 1, MovedNodes: 1},
+			wantSettled: true,
+		},
+		{
+			name: "rollout replacements on the same nodes",
+			steps: func(tracker *churnTracker) {
+				tracker.disrupted([]*corev1.Pod{pod("a", "n1", before, true), pod("b", "n2", before, true)})
+				tracker.observe(pod("c", "n2", time.Now(), true))
+				tracker.observe(pod("d", "n1", time.Now(), true))
+				tracker.observe(pod("e", "n1", time.Now(), true))
+			},
+			want:        ChurnResult{Disruptions: 1, DeletedPods: 2, Replacements: 2, Ready: 2},
+			wantSettled: true,
+		},
+		{
+			name: "replacement deleted before it is ready is replaced by its successor",
+			steps: func(tracker *churnTracker) {
+				tracker.disrupted([]*corev1.Pod{pod("old", "n1", before, true)})
+				tracker.observe(pod("new", "n1", time.Now(), false))
+				tracker.deleted(cache.DeletedFinalStateUnknown{Key: "new", Obj: pod("new", "n1", time.Now(), false)})
+				tracker.observe(pod("successor", "n2", time.Now(), true))
+			},
+			want:        ChurnResult{Disruptions: 1, DeletedPods: 1, Replacements: 1, Ready: 1, MovedNodes: 1},
+			wantSettled: true,
+		},
+		{
+			name: "replacement deleted before it is ready stays pending without a successor",
+			steps: func(tracker *churnTracker) {
+				tracker.disrupted([]*corev1.Pod{pod("a", "n1", before, true)})
+				tracker.observe(pod("b", "n1", time.Now(), false))
+				tracker.disrupted([]*corev1.Pod{pod("c", "n2", before, true)})
+				tracker.deleted(pod("b", "n1", time.Now(), false))
+				tracker.observe(pod("d", "n1", time.Now(), true))
+			},
+			want: ChurnResult{Disruptions: 2, DeletedPods: 2, Replacements: 1, Ready: 1},
+		},
+		{
+			name: "ready replacements stay counted when they are deleted",
+			steps: func(tracker *churnTracker) {
+				tracker.disrupted([]*corev1.Pod{pod("old", "n1", before, true)})
+				tracker.observe(pod("new", "n1", time.Now(), true))
+				tracker.deleted(pod("new", "n1", time.Now(), true))
+			},
+			want:        ChurnResult{Disruptions: 1, DeletedPods: 1, Replacements: 1, Ready: 1},
+			wantSettled: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			tracker := newChurnTracker(ChurnOptions{})
+			tc.steps(tracker)
+			got := tracker.summarize()
+			got.LatencyP50, got.LatencyP90, got.LatencyMax = 0, 0, 0
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("summarize() = %+v, want %+v", got, tc.want)
+			}
+			if settled := tracker.settled(); settled != tc.wantSettled {
+				t.Errorf("settled() = %t, want %t", settled, tc.wantSettled)
+			}
+		})
+	}
+}
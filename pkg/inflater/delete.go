@@ -0,0 +1,221 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-logr/logr"
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	OperationDeleted = "deleted"
+	OperationFailed  = "failed"
+	// OperationWouldDelete is the result of a dry run
+	OperationWouldDelete = "would delete"
+	// OperationProtected is the result of a deployment that was skipped because of AnnotationProtect
+	OperationProtected = "protected"
+)
+
+type DeleteFilters struct {
+	Namespace string
+	Name      string
+}
+
+// DeleteOptions select the inflates to delete
+type DeleteOptions struct {
+	Filters DeleteFilters
+	// Force deletes inflates with AnnotationProtect too
+	Force bool
+	// DryRun returns what would be deleted without deleting anything
+	DryRun bool
+	// Objects limits the delete to these objects unless nil, e.g. to the results of a confirmed dry run
+	// so that objects created since are not deleted
+	Objects []DeleteResult
+}
+
+// selected is true when the object is one of the Objects to delete
+func (o DeleteOptions) selected(kind, namespace, name string) bool {
+	return o.Objects == nil || lo.ContainsBy(o.Objects, func(object DeleteResult) bool {
+		return object.Kind == kind && object.Namespace == namespace && object.Name == name
+	})
+}
+
+// DeleteResult describes what deleting a single object did, Error is set when it failed
+type DeleteResult struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Operation string
+	Error     string
+}
+
+// deletion collects the results and errors of deleting objects one by one
+type deletion struct {
+	logger  logr.Logger
+	opts    DeleteOptions
+	results []DeleteResult
+	errs    error
+}
+
+// delete deletes an object, an object that is already gone or not selected is not a result
+func (d *deletion) delete(kind, namespace, name string, deleteFn func() error) {
+	if !d.opts.selected(kind, namespace, name) {
+		return
+	}
+	if d.opts.DryRun {
+		d.results = append(d.results, DeleteResult{Kind: kind, Namespace: namespace, Name: name, Operation: OperationWouldDelete})
+		return
+	}
+	d.logger.V(1).Info("deleting", "kind", kind, "namespace", namespace, "name", name)
+	err := withRetry(d.logger, deleteFn)
+	if errors.IsNotFound(err) {
+		return
+	}
+	result := DeleteResult{Kind: kind, Namespace: namespace, Name: name, Operation: OperationDeleted}
+	if err != nil {
+		result.Operation = OperationFailed
+		result.Error = err.Error()
+		d.errs = multierr.Append(d.errs, fmt.Errorf("deleting %s %s, %w", kind, lo.Ternary(namespace == "", name, namespace+"/"+name), err))
+	}
+	d.results = append(d.results, result)
+}
+
+func (d *deletion) fail(err error) {
+	d.errs = multierr.Append(d.errs, err)
+}
+
+func (d *deletion) protected(kind, namespace, name string) {
+	d.results = append(d.results, DeleteResult{Kind: kind, Namespace: namespace, Name: name, Operation: OperationProtected})
+}
+
+// Delete deletes the deployments, services and HPAs of the inflates matching the filters and the priority classes they no longer use.
+// Inflates with AnnotationProtect are skipped unless forced. It continues past failures and returns a result per object,
+// the error is a PartialError if some objects were deleted. NotFoundError is returned when there was nothing to delete.
+func (i Inflater) Delete(ctx context.Context, opts DeleteOptions) ([]DeleteResult, error) {
+	namespaces, err := i.managedNamespaces(ctx, opts.Filters.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	listOptions := metav1.ListOptions{LabelSelector: inflateSelector(opts.Filters.Name)}
+	d := &deletion{logger: i.logger, opts: opts}
+	var priorityClassNames []string
+	deleted := map[types.NamespacedName]struct{}{}
+	for _, ns := range namespaces {
+		// the service and HPA of a protected inflate are skipped along with its deployment
+		protected := map[string]struct{}{}
+		deploymentList, err := i.clientset.AppsV1().Deployments(ns).List(ctx, listOptions)
+		if err != nil {
+			d.fail(fmt.Errorf("listing deployments in namespace %s, %w", ns, err))
+		} else {
+			for _, deployment := range deploymentList.Items {
+				if !opts.selected("Deployment", ns, deployment.Name) {
+					continue
+				}
+				if deployment.Annotations[AnnotationProtect] == "true" && !opts.Force {
+					protected[deployment.Labels["app"]] = struct{}{}
+					d.protected("Deployment", ns, deployment.Name)
+					continue
+				}
+				priorityClassNames = append(priorityClassNames, deployment.Spec.Template.Spec.PriorityClassName)
+				deleted[types.NamespacedName{Namespace: ns, Name: deployment.Name}] = struct{}{}
+				d.delete("Deployment", ns, deployment.Name, func() error {
+					return i.clientset.AppsV1().Deployments(ns).Delete(ctx, deployment.Name, metav1.DeleteOptions{})
+				})
+			}
+		}
+		serviceList, err := i.clientset.CoreV1().Services(ns).List(ctx, listOptions)
+		if err != nil {
+			d.fail(fmt.Errorf("listing services in namespace %s, %w", ns, err))
+		} else {
+			for _, service := range serviceList.Items {
+				if _, ok := protected[service.Labels["app"]]; ok {
+					continue
+				}
+				d.delete("Service", ns, service.Name, func() error {
+					return i.clientset.CoreV1().Services(ns).Delete(ctx, service.Name, metav1.DeleteOptions{})
+				})
+			}
+		}
+		hpaList, err := i.clientset.AutoscalingV2().HorizontalPodAutoscalers(ns).List(ctx, listOptions)
+		if err != nil {
+			d.fail(fmt.Errorf("listing horizontal pod autoscalers in namespace %s, %w", ns, err))
+		} else {
+			for _, hpa := range hpaList.Items {
+				if _, ok := protected[hpa.Labels["app"]]; ok {
+					continue
+				}
+				d.delete("HorizontalPodAutoscaler", ns, hpa.Name, func() error {
+					return i.clientset.AutoscalingV2().HorizontalPodAutoscalers(ns).Delete(ctx, hpa.Name, metav1.DeleteOptions{})
+				})
+			}
+		}
+	}
+	i.deleteUnusedPriorityClasses(ctx, d, priorityClassNames, deleted)
+
+	switch {
+	case d.errs == nil && len(d.results) == 0:
+		return nil, &NotFoundError{Namespace: opts.Filters.Namespace, Name: opts.Filters.Name}
+	case d.errs != nil && lo.ContainsBy(d.results, func(result DeleteResult) bool { return result.Operation == OperationDeleted }):
+		return d.results, &PartialError{Errs: multierr.Errors(d.errs)}
+	default:
+		return d.results, d.errs
+	}
+}
+
+// deleteUnusedPriorityClasses deletes the inflate managed priority classes in names
+// that are no longer referenced by any inflate deployment other than the deleted ones
+func (i Inflater) deleteUnusedPriorityClasses(ctx context.Context, d *deletion, names []string, deleted map[types.NamespacedName]struct{}) {
+	names = lo.Compact(lo.Uniq(names))
+	if len(names) == 0 {
+		return
+	}
+	deploymentList, err := i.clientset.AppsV1().Deployments("").List(ctx, metav1.ListOptions{
+		LabelSelector: "managed-by=inflate",
+	})
+	if err != nil {
+		d.fail(fmt.Errorf("listing deployments using priority classes, %w", err))
+		return
+	}
+	inUse := lo.Map(lo.Filter(deploymentList.Items, func(deployment appsv1.Deployment, _ int) bool {
+		_, ok := deleted[types.NamespacedName{Namespace: deployment.Namespace, Name: deployment.Name}]
+		return deployment.DeletionTimestamp == nil && !ok
+	}), func(deployment appsv1.Deployment, _ int) string {
+		return deployment.Spec.Template.Spec.PriorityClassName
+	})
+	for _, name := range lo.Without(names, inUse...) {
+		priorityClass, err := i.clientset.SchedulingV1().PriorityClasses().Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			d.fail(fmt.Errorf("getting priority class %s, %w", name, err))
+			continue
+		}
+		if priorityClass.Labels["managed-by"] != "inflate" {
+			continue
+		}
+		d.delete("PriorityClass", "", name, func() error {
+			return i.clientset.SchedulingV1().PriorityClasses().Delete(ctx, name, metav1.DeleteOptions{})
+		})
+	}
+}
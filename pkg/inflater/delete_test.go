@@ -0,0 +1,144 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// deleteObjects are the objects of the web and api inflates and of the batch inflate created since the dry run,
+// api and batch share a priority class
+func deleteObjects() []runtime.Object {
+	labels := func(app string) map[string]string { return map[string]string{"app": app, "managed-by": "inflate"} }
+	deployment := func(app, priorityClassName string) *appsv1.Deployment {
+		return &appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: app, Labels: labels(app)},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{PriorityClassName: priorityClassName},
+			}},
+		}
+	}
+	priorityClass := func(name string) *schedulingv1.PriorityClass {
+		return &schedulingv1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"managed-by": "inflate"}}}
+	}
+	return []runtime.Object{
+		deployment("web", "web"),
+		deployment("api", "shared"),
+		deployment("batch", "shared"),
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: "web", Labels: labels("web")}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: "batch", Labels: labels("batch")}},
+		priorityClass("web"),
+		priorityClass("shared"),
+	}
+}
+
+func deletedResult(kind, namespace, name string) DeleteResult {
+	return DeleteResult{Kind: kind, Namespace: namespace, Name: name, Operation: OperationDeleted}
+}
+
+func TestDeleteObjects(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		objects []DeleteResult
+		want    []DeleteResult
+		// remaining are the deployments left
+		remaining []string
+		notFound  bool
+	}{
+		{
+			name: "every inflate without objects",
+			want: []DeleteResult{
+				deletedResult("Deployment", "inflate", "api"),
+				deletedResult("Deployment", "inflate", "batch"),
+				deletedResult("Deployment", "inflate", "web"),
+				deletedResult("PriorityClass", "", "shared"),
+				deletedResult("PriorityClass", "", "web"),
+				deletedResult("Service", "inflate", "batch"),
+				deletedResult("Service", "inflate", "web"),
+			},
+		},
+		{
+			name: "only the confirmed objects",
+			objects: []DeleteResult{
+				{Kind: "Deployment", Namespace: "inflate", Name: "web"},
+				{Kind: "Service", Namespace: "inflate", Name: "web"},
+				{Kind: "PriorityClass", Name: "web"},
+			},
+			want: []DeleteResult{
+				deletedResult("Deployment", "inflate", "web"),
+				deletedResult("PriorityClass", "", "web"),
+				deletedResult("Service", "inflate", "web"),
+			},
+			remaining: []string{"api", "batch"},
+		},
+		{
+			name: "a priority class still used by an inflate created since",
+			objects: []DeleteResult{
+				{Kind: "Deployment", Namespace: "inflate", Name: "api"},
+				{Kind: "PriorityClass", Name: "shared"},
+			},
+			want:      []DeleteResult{deletedResult("Deployment", "inflate", "api")},
+			remaining: []string{"batch", "web"},
+		},
+		{
+			name:      "nothing confirmed",
+			objects:   []DeleteResult{},
+			remaining: []string{"api", "batch", "web"},
+			notFound:  true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			clientset := fake.NewSimpleClientset(deleteObjects()...)
+			results, err := New(clientset).Delete(ctx, DeleteOptions{Filters: DeleteFilters{Namespace: "inflate"}, Objects: tc.objects})
+			var notFound *NotFoundError
+			if tc.notFound != errors.As(err, &notFound) || (!tc.notFound && err != nil) {
+				t.Fatalf("Delete() error = %v, want not found %t", err, tc.notFound)
+			}
+			sort.Slice(results, func(a, b int) bool {
+				if results[a].Kind != results[b].Kind {
+					return results[a].Kind < results[b].Kind
+				}
+				return results[a].Name < results[b].Name
+			})
+			if !reflect.DeepEqual(results, tc.want) {
+				t.Errorf("Delete() = %v, want %v", results, tc.want)
+			}
+			deploymentList, err := clientset.AppsV1().Deployments("inflate").List(ctx, metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var remaining []string
+			for _, deployment := range deploymentList.Items {
+				remaining = append(remaining, deployment.Name)
+			}
+			sort.Strings(remaining)
+			if !reflect.DeepEqual(remaining, tc.remaining) {
+				t.Errorf("remaining deployments = %v, want %v", remaining, tc.remaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,194 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// ObjectDiff is the difference between the live and the desired state of a single inflate object
+type ObjectDiff struct {
+	Kind      string
+	Namespace string
+	Name      string
+	// Diff is a unified diff of the normalized YAML, empty when the live object is up to date
+	Diff string
+}
+
+// Diff renders the inflate described by opts and compares it to the objects in the cluster.
+// Existing objects are compared against a server-side dry-run apply so that API defaults are not reported.
+func (i Inflater) Diff(ctx context.Context, opts Options) ([]ObjectDiff, error) {
+	opts, err := mergeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	deployment, err := i.GetInflateDeployment(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	var diffs []ObjectDiff
+	if opts.PriorityClassValue != nil {
+		priorityClass, err := i.GetPriorityClass(ctx, deployment.Spec.Template.Spec.PriorityClassName, opts)
+		if err != nil {
+			return nil, err
+		}
+		objectDiff, err := diffObject[*schedulingv1.PriorityClass](ctx, i.clientset.SchedulingV1().PriorityClasses(), priorityClass, opts.ForceConflicts)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, objectDiff)
+	}
+	objectDiff, err := diffObject[*appsv1.Deployment](ctx, i.clientset.AppsV1().Deployments(opts.Namespace), deployment, opts.ForceConflicts)
+	if err != nil {
+		return nil, err
+	}
+	diffs = append(diffs, objectDiff)
+	if opts.Service {
+		service, err := i.GetService(ctx, deployment.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		objectDiff, err := diffObject[*corev1.Service](ctx, i.clientset.CoreV1().Services(opts.Namespace), service, opts.ForceConflicts)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, objectDiff)
+	}
+	if opts.HPA != nil {
+		hpa, err := i.GetHorizontalPodAutoscaler(ctx, deployment.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+		objectDiff, err := diffObject[*autoscalingv2.HorizontalPodAutoscaler](ctx, i.clientset.AutoscalingV2().HorizontalPodAutoscalers(opts.Namespace), hpa, opts.ForceConflicts)
+		if err != nil {
+			return nil, err
+		}
+		diffs = append(diffs, objectDiff)
+	}
+	return diffs, nil
+}
+
+func diffObject[T object](ctx context.Context, client applyClient[T], obj T, force bool) (ObjectDiff, error) {
+	objectDiff := ObjectDiff{
+		Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+	var liveYAML string
+	desired := obj
+	live, err := client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return objectDiff, err
+	default:
+		// typed clients drop the TypeMeta of decoded objects
+		live.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+		if liveYAML, err = NormalizedYAML(live); err != nil {
+			return objectDiff, err
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return objectDiff, err
+		}
+		desired, err = client.Patch(ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+			FieldManager: FieldManager,
+			Force:        &force,
+			DryRun:       []string{metav1.DryRunAll},
+		})
+		if err != nil {
+			return objectDiff, err
+		}
+		desired.GetObjectKind().SetGroupVersionKind(obj.GetObjectKind().GroupVersionKind())
+	}
+	desiredYAML, err := NormalizedYAML(desired)
+	if err != nil {
+		return objectDiff, err
+	}
+	name := objectDiff.Name
+	if objectDiff.Namespace != "" {
+		name = fmt.Sprintf("%s/%s", objectDiff.Namespace, objectDiff.Name)
+	}
+	objectDiff.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(liveYAML),
+		B:        difflib.SplitLines(desiredYAML),
+		FromFile: fmt.Sprintf("live/%s/%s", objectDiff.Kind, name),
+		ToFile:   fmt.Sprintf("desired/%s/%s", objectDiff.Kind, name),
+		Context:  3,
+	})
+	return objectDiff, err
+}
+
+// NormalizedYAML renders obj as YAML without status, server-populated metadata or empty fields
+func NormalizedYAML(obj runtime.Object) (string, error) {
+	fields, err := NormalizedFields(obj)
+	if err != nil {
+		return "", err
+	}
+	out, err := yaml.Marshal(fields)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// NormalizedFields converts obj to its unstructured form without status, server-populated metadata or empty fields
+func NormalizedFields(obj runtime.Object) (map[string]any, error) {
+	fields, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	delete(fields, "status")
+	if metadata, ok := fields["metadata"].(map[string]any); ok {
+		for _, key := range []string{"managedFields", "resourceVersion", "uid", "generation", "creationTimestamp", "selfLink"} {
+			delete(metadata, key)
+		}
+	}
+	pruned, _ := pruneEmpty(fields).(map[string]any)
+	return pruned, nil
+}
+
+// pruneEmpty drops nil values and empty maps and lists, e.g. "strategy: {}" or "creationTimestamp: null"
+func pruneEmpty(value any) any {
+	switch typed := value.(type) {
+	case map[string]any:
+		pruned := map[string]any{}
+		for key, elem := range typed {
+			if elem = pruneEmpty(elem); elem != nil {
+				pruned[key] = elem
+			}
+		}
+		return lo.Ternary[any](len(pruned) == 0, nil, pruned)
+	case []any:
+		pruned := lo.Compact(lo.Map(typed, func(elem any, _ int) any { return pruneEmpty(elem) }))
+		return lo.Ternary[any](len(pruned) == 0, nil, pruned)
+	default:
+		return value
+	}
+}
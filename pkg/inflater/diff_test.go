@@ -0,0 +1,88 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestPruneEmpty(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{name: "scalar", value: "x", want: "x"},
+		{name: "nil", value: nil, want: nil},
+		{name: "empty map", value: map[string]any{}, want: nil},
+		{name: "empty list", value: []any{}, want: nil},
+		{
+			name:  "nested empties are dropped",
+			value: map[string]any{"strategy": map[string]any{}, "creationTimestamp": nil, "replicas": int64(1)},
+			want:  map[string]any{"replicas": int64(1)},
+		},
+		{
+			name:  "list of empties",
+			value: map[string]any{"containers": []any{map[string]any{"resources": map[string]any{}}}},
+			want:  nil,
+		},
+		{
+			name:  "false and zero are kept",
+			value: map[string]any{"hostNetwork": false, "maxSkew": int64(0)},
+			want:  map[string]any{"hostNetwork": false, "maxSkew": int64(0)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := pruneEmpty(tc.value); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("pruneEmpty() = %#v, want %#v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestNormalizedYAML(t *testing.T) {
+	service := &corev1.Service{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "inflate",
+			Namespace:       "inflate",
+			ResourceVersion: "7",
+			UID:             "uid",
+			ManagedFields:   []metav1.ManagedFieldsEntry{{Manager: "inflate"}},
+		},
+		Spec:   corev1.ServiceSpec{Selector: map[string]string{"app": "inflate"}},
+		Status: corev1.ServiceStatus{LoadBalancer: corev1.LoadBalancerStatus{Ingress: []corev1.LoadBalancerIngress{{IP: "1.2.3.4"}}}},
+	}
+	got, err := NormalizedYAML(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `apiVersion: v1
+kind: Service
+metadata:
+  name: inflate
+  namespace: inflate
+spec:
+  selector:
+    app: inflate
+`
+	if got != want {
+		t.Errorf("NormalizedYAML() =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,286 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const (
+	// DisruptModeCordon cordons the nodes and evicts only the inflate pods from them
+	DisruptModeCordon = "cordon"
+	// DisruptModeDrain cordons the nodes and evicts every pod that is not a DaemonSet or mirror pod
+	DisruptModeDrain = "drain"
+	// DisruptModeDelete drains the nodes and then deletes them
+	DisruptModeDelete = "delete"
+)
+
+// evictionRetryInterval is how long to wait before retrying an eviction blocked by a PodDisruptionBudget
+var evictionRetryInterval = 5 * time.Second
+
+// DisruptOptions configure which nodes running inflate pods are disrupted and how
+type DisruptOptions struct {
+	Filters ListFilters
+	Nodes   int
+	Mode    string
+	// Timeout bounds evicting the pods and waiting for the replicas to be ready again
+	Timeout time.Duration
+	// KeepCordoned leaves the nodes cordoned at the end instead of restoring them
+	KeepCordoned bool
+}
+
+// DisruptResult describes a disruption and how long the inflates took to recover
+type DisruptResult struct {
+	Mode        string
+	Nodes       []string
+	EvictedPods int
+	// BlockedEvictions counts the evictions that were refused, e.g. by a PodDisruptionBudget, and retried
+	BlockedEvictions int
+	// TimeToReady is how long it took until every inflate had all of its replicas ready on other nodes
+	TimeToReady time.Duration
+	TimedOut    bool
+	// Uncordoned are the nodes whose cordon was restored
+	Uncordoned []string
+}
+
+// Disrupt cordons, drains or deletes nodes running the pods of the inflates matching the filters
+// and measures how long it takes for all replicas to be ready elsewhere. Pods are evicted so PodDisruptionBudgets are respected.
+func (i Inflater) Disrupt(ctx context.Context, opts DisruptOptions) (result DisruptResult, err error) {
+	result.Mode = opts.Mode
+	deployments, err := i.List(ctx, opts.Filters)
+	if err != nil {
+		return result, err
+	}
+	if len(deployments) == 0 {
+		return result, &NotFoundError{Namespace: opts.Filters.Namespace, Name: opts.Filters.Name}
+	}
+	nodes, err := i.inflateNodes(ctx, deployments)
+	if err != nil {
+		return result, err
+	}
+	if len(nodes) == 0 {
+		return result, fmt.Errorf("no nodes are running inflate pods")
+	}
+	rand.Shuffle(len(nodes), func(a, b int) { nodes[a], nodes[b] = nodes[b], nodes[a] })
+	result.Nodes = nodes[:lo.Clamp(opts.Nodes, 1, len(nodes))]
+	sort.Strings(result.Nodes)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
+	defer cancel()
+	start := time.Now()
+	var cordoned, deleted []string
+	// the deferred restore records the uncordoned nodes in the named result, in delete mode it
+	// uncordons the nodes that were not deleted because an error ended the disruption early
+	defer func() {
+		if opts.KeepCordoned {
+			return
+		}
+		// restore with a fresh context since ctx may be done
+		restoreCtx, restoreCancel := context.WithTimeout(context.Background(), time.Minute)
+		defer restoreCancel()
+		for _, node := range lo.Without(cordoned, deleted...) {
+			if err := i.setUnschedulable(restoreCtx, node, false); err == nil {
+				result.Uncordoned = append(result.Uncordoned, node)
+			}
+		}
+	}()
+	for _, node := range result.Nodes {
+		wasCordoned, err := i.cordon(ctx, node)
+		if err != nil {
+			return result, err
+		}
+		if !wasCordoned {
+			cordoned = append(cordoned, node)
+		}
+	}
+	for _, node := range result.Nodes {
+		evicted, blocked, err := i.evictPods(ctx, node, opts.Mode == DisruptModeCordon)
+		result.EvictedPods += evicted
+		result.BlockedEvictions += blocked
+		if err != nil {
+			if result.TimedOut = ctx.Err() != nil; result.TimedOut {
+				return result, &TimeoutError{Operation: "evicting pods", Timeout: opts.Timeout, Err: err}
+			}
+			return result, err
+		}
+		if opts.Mode == DisruptModeDelete {
+			i.logger.V(1).Info("deleting node", "name", node)
+			if err := withRetry(i.logger, func() error {
+				return i.clientset.CoreV1().Nodes().Delete(ctx, node, metav1.DeleteOptions{})
+			}); err != nil && !errors.IsNotFound(err) {
+				return result, err
+			}
+			deleted = append(deleted, node)
+		}
+	}
+	for {
+		recovered, err := i.recovered(ctx, deployments, result.Nodes)
+		if err != nil && ctx.Err() == nil {
+			return result, err
+		}
+		if recovered {
+			result.TimeToReady = time.Since(start)
+			return result, nil
+		}
+		select {
+		case <-ctx.Done():
+			result.TimedOut = true
+			result.TimeToReady = time.Since(start)
+			return result, &TimeoutError{Operation: "waiting for the replicas to be ready", Timeout: opts.Timeout}
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+// inflateNodes returns the nodes running pods of the deployments
+func (i Inflater) inflateNodes(ctx context.Context, deployments []appsv1.Deployment) ([]string, error) {
+	var nodes []string
+	for _, deployment := range deployments {
+		pods, err := i.deploymentPods(ctx, deployment)
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range pods {
+			if pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil {
+				nodes = append(nodes, pod.Spec.NodeName)
+			}
+		}
+	}
+	return lo.Uniq(nodes), nil
+}
+
+func (i Inflater) deploymentPods(ctx context.Context, deployment appsv1.Deployment) ([]corev1.Pod, error) {
+	podList, err := i.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s,managed-by=inflate", deployment.Name),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return podList.Items, nil
+}
+
+// cordon marks the node unschedulable and returns whether it already was
+func (i Inflater) cordon(ctx context.Context, name string) (bool, error) {
+	node, err := i.clientset.CoreV1().Nodes().Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	if node.Spec.Unschedulable {
+		return true, nil
+	}
+	return false, i.setUnschedulable(ctx, name, true)
+}
+
+func (i Inflater) setUnschedulable(ctx context.Context, name string, unschedulable bool) error {
+	patch := fmt.Sprintf(`{"spec":{"unschedulable":%t}}`, unschedulable)
+	return withRetry(i.logger, func() error {
+		_, err := i.clientset.CoreV1().Nodes().Patch(ctx, name, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+		return err
+	})
+}
+
+// evictPods evicts the pods on the node, only the inflate pods if inflateOnly, retrying evictions refused by
+// PodDisruptionBudgets until ctx is done. It returns the number of evicted pods and of refused eviction attempts.
+func (i Inflater) evictPods(ctx context.Context, node string, inflateOnly bool) (int, int, error) {
+	listOptions := metav1.ListOptions{FieldSelector: fmt.Sprintf("spec.nodeName=%s", node)}
+	if inflateOnly {
+		listOptions.LabelSelector = "managed-by=inflate"
+	}
+	podList, err := i.clientset.CoreV1().Pods(metav1.NamespaceAll).List(ctx, listOptions)
+	if err != nil {
+		return 0, 0, err
+	}
+	pending := lo.Filter(podList.Items, func(pod corev1.Pod, _ int) bool { return evictable(pod) })
+	evicted, blocked := 0, 0
+	for len(pending) > 0 {
+		var errs error
+		var retry []corev1.Pod
+		for _, pod := range pending {
+			err := i.clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, &policyv1.Eviction{
+				ObjectMeta: metav1.ObjectMeta{Name: pod.Name, Namespace: pod.Namespace},
+			})
+			switch {
+			case err == nil:
+				i.logger.V(1).Info("evicted pod", "namespace", pod.Namespace, "name", pod.Name, "node", node)
+				evicted++
+			case errors.IsNotFound(err):
+			case errors.IsTooManyRequests(err):
+				i.logger.V(1).Info("eviction blocked, retrying", "namespace", pod.Namespace, "name", pod.Name, "after", evictionRetryInterval.String(), "error", err.Error())
+				blocked++
+				retry = append(retry, pod)
+			default:
+				errs = multierr.Append(errs, fmt.Errorf("evicting pod %s/%s, %w", pod.Namespace, pod.Name, err))
+			}
+		}
+		if errs != nil {
+			return evicted, blocked, errs
+		}
+		pending = retry
+		if len(pending) == 0 {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return evicted, blocked, fmt.Errorf("%d pods on node %s could not be evicted, %w", len(pending), node, ctx.Err())
+		case <-time.After(evictionRetryInterval):
+		}
+	}
+	return evicted, blocked, nil
+}
+
+// evictable skips DaemonSet pods, which would be recreated on the node, mirror pods and terminating or finished pods
+func evictable(pod corev1.Pod) bool {
+	if _, ok := pod.Annotations[corev1.MirrorPodAnnotationKey]; ok {
+		return false
+	}
+	if pod.DeletionTimestamp != nil || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+		return false
+	}
+	return !lo.ContainsBy(pod.OwnerReferences, func(owner metav1.OwnerReference) bool { return owner.Kind == "DaemonSet" })
+}
+
+// recovered is true once every deployment has its desired replicas ready on nodes other than the disrupted ones
+func (i Inflater) recovered(ctx context.Context, deployments []appsv1.Deployment, disrupted []string) (bool, error) {
+	for _, deployment := range deployments {
+		live, err := i.clientset.AppsV1().Deployments(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		pods, err := i.deploymentPods(ctx, deployment)
+		if err != nil {
+			return false, err
+		}
+		ready := lo.CountBy(pods, func(pod corev1.Pod) bool {
+			return pod.DeletionTimestamp == nil && isReady(&pod) && !lo.Contains(disrupted, pod.Spec.NodeName)
+		})
+		if ready < int(lo.FromPtrOr(live.Spec.Replicas, 1)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
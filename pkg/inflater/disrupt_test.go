@@ -0,0 +1,357 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var podsResource = schema.GroupVersionResource{Version: "v1", Resource: "pods"}
+
+// disruptPod is a ready pod of the web inflate on the node, labels replace the inflate labels when given
+func disruptPod(name string, node string, podLabels map[string]string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace: "inflate",
+			Name:      name,
+			Labels:    lo.Ternary(podLabels != nil, podLabels, map[string]string{"app": "web", "managed-by": "inflate"}),
+		},
+		Spec: corev1.PodSpec{NodeName: node},
+		Status: corev1.PodStatus{
+			Phase:      corev1.PodRunning,
+			Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+		},
+	}
+}
+
+func disruptNode(name string, unschedulable bool) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name}, Spec: corev1.NodeSpec{Unschedulable: unschedulable}}
+}
+
+func webDeployment(replicas int32) *appsv1.Deployment {
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: "web", Labels: map[string]string{"app": "web", "managed-by": "inflate"}},
+		Spec:       appsv1.DeploymentSpec{Replicas: lo.ToPtr(replicas)},
+	}
+}
+
+// evictions makes the evictions of the clientset delete the pod, or fail with the error that blocked returns for the pod
+func evictions(clientset *fake.Clientset, blocked func(name string) error) {
+	clientset.PrependReactor("create", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		if action.GetSubresource() != "eviction" {
+			return false, nil, nil
+		}
+		eviction := action.(k8stesting.CreateAction).GetObject().(*policyv1.Eviction)
+		if err := blocked(eviction.Name); err != nil {
+			return true, nil, err
+		}
+		return true, nil, clientset.Tracker().Delete(podsResource, eviction.Namespace, eviction.Name)
+	})
+}
+
+// podsByNode makes the pod lists of the clientset honor the spec.nodeName field selector, which the fake clientset ignores
+func podsByNode(clientset *fake.Clientset) {
+	clientset.PrependReactor("list", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		restrictions := action.(k8stesting.ListAction).GetListRestrictions()
+		obj, err := clientset.Tracker().List(podsResource, corev1.SchemeGroupVersion.WithKind("Pod"), action.GetNamespace())
+		if err != nil {
+			return true, nil, err
+		}
+		podList := obj.(*corev1.PodList)
+		podList.Items = lo.Filter(podList.Items, func(pod corev1.Pod, _ int) bool {
+			return restrictions.Labels.Matches(labels.Set(pod.Labels)) && restrictions.Fields.Matches(fields.Set{"spec.nodeName": pod.Spec.NodeName})
+		})
+		return true, podList, nil
+	})
+}
+
+func TestEvictable(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		pod  corev1.Pod
+		want bool
+	}{
+		{name: "running pod", pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodRunning}}, want: true},
+		{name: "pending pod", pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodPending}}, want: true},
+		{
+			name: "replica set pod",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "ReplicaSet", Name: "web-1"}}}},
+			want: true,
+		},
+		{
+			name: "mirror pod",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{corev1.MirrorPodAnnotationKey: "hash"}}},
+		},
+		{
+			name: "daemon set pod",
+			pod:  corev1.Pod{ObjectMeta: metav1.ObjectMeta{OwnerReferences: []metav1.OwnerReference{{Kind: "DaemonSet", Name: "kube-proxy"}}}},
+		},
+		{name: "terminating pod", pod: corev1.Pod{ObjectMeta: metav1.ObjectMeta{DeletionTimestamp: lo.ToPtr(metav1.Now())}}},
+		{name: "succeeded pod", pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodSucceeded}}},
+		{name: "failed pod", pod: corev1.Pod{Status: corev1.PodStatus{Phase: corev1.PodFailed}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := evictable(tc.pod); got != tc.want {
+				t.Errorf("evictable() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestEvictPods(t *testing.T) {
+	defer func(interval time.Duration) { evictionRetryInterval = interval }(evictionRetryInterval)
+	evictionRetryInterval = time.Millisecond
+	tooManyRequests := apierrors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+	for _, tc := range []struct {
+		name        string
+		inflateOnly bool
+		// refusals is how many times the eviction of each pod is refused before it succeeds, -1 refuses it forever
+		refusals    map[string]int
+		err         error
+		timeout     time.Duration
+		wantEvicted int
+		wantBlocked int
+		wantLeft    []string
+		wantErr     error
+	}{
+		{name: "inflate pods", inflateOnly: true, wantEvicted: 1, wantLeft: []string{"other"}},
+		{name: "every pod", wantEvicted: 2},
+		{
+			name:        "evictions blocked by a disruption budget are retried",
+			inflateOnly: true,
+			refusals:    map[string]int{"web": 2},
+			wantEvicted: 1,
+			wantBlocked: 2,
+			wantLeft:    []string{"other"},
+		},
+		{
+			name:        "blocked evictions give up when the context ends",
+			refusals:    map[string]int{"web": -1},
+			timeout:     50 * time.Millisecond,
+			wantEvicted: 1,
+			wantLeft:    []string{"web"},
+			wantErr:     context.DeadlineExceeded,
+		},
+		{
+			name:     "other errors are returned",
+			err:      apierrors.NewForbidden(podsResource.GroupResource(), "web", errors.New("denied")),
+			wantLeft: []string{"other", "web"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			daemonSetPod := disruptPod("daemon", "n1", map[string]string{"app": "proxy"})
+			daemonSetPod.OwnerReferences = []metav1.OwnerReference{{Kind: "DaemonSet", Name: "proxy"}}
+			clientset := fake.NewSimpleClientset(disruptPod("web", "n1", nil), disruptPod("other", "n1", map[string]string{"app": "other"}), daemonSetPod)
+			podsByNode(clientset)
+			refused := map[string]int{}
+			evictions(clientset, func(name string) error {
+				if tc.err != nil {
+					return tc.err
+				}
+				if refusals := tc.refusals[name]; refusals == -1 || refused[name] < refusals {
+					refused[name]++
+					return tooManyRequests
+				}
+				return nil
+			})
+			ctx := context.Background()
+			if tc.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tc.timeout)
+				defer cancel()
+			}
+
+			evicted, blocked, err := New(clientset).evictPods(ctx, "n1", tc.inflateOnly)
+			switch {
+			case tc.err != nil:
+				if !errors.Is(err, tc.err) {
+					t.Errorf("evictPods() error = %v, want %v", err, tc.err)
+				}
+			case !errors.Is(err, tc.wantErr):
+				t.Errorf("evictPods() error = %v, want %v", err, tc.wantErr)
+			}
+			if evicted != tc.wantEvicted {
+				t.Errorf("evictPods() evicted = %d, want %d", evicted, tc.wantEvicted)
+			}
+			if tc.timeout == 0 && blocked != tc.wantBlocked {
+				t.Errorf("evictPods() blocked = %d, want %d", blocked, tc.wantBlocked)
+			} else if tc.timeout > 0 && blocked == 0 {
+				t.Error("evictPods() blocked = 0, want the refused evictions")
+			}
+			podList, err := clientset.CoreV1().Pods("inflate").List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			left := lo.Without(lo.Map(podList.Items, func(pod corev1.Pod, _ int) string { return pod.Name }), "daemon")
+			if !reflect.DeepEqual(left, lo.Ternary(tc.wantLeft != nil, tc.wantLeft, []string{})) {
+				t.Errorf("pods left = %v, want %v", left, tc.wantLeft)
+			}
+			listAction, ok := lo.Find(clientset.Actions(), func(action k8stesting.Action) bool { return action.GetVerb() == "list" })
+			if !ok || listAction.(k8stesting.ListAction).GetListRestrictions().Fields.String() != "spec.nodeName=n1" {
+				t.Errorf("pods were not listed by node, %+v", listAction)
+			}
+		})
+	}
+}
+
+func TestDisruptRestore(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		opts    DisruptOptions
+		objects []runtime.Object
+		// replace creates a ready replacement on the spare node when a pod is evicted
+		replace bool
+		// forbidden is the node whose delete is forbidden
+		forbidden         string
+		want              DisruptResult
+		wantErr           bool
+		wantUnschedulable []string
+		wantDeleted       []string
+	}{
+		{
+			name:              "cordoned nodes are uncordoned once the replicas are ready elsewhere",
+			opts:              DisruptOptions{Nodes: 1, Mode: DisruptModeCordon},
+			objects:           []runtime.Object{webDeployment(1), disruptPod("web-a", "n1", nil), disruptNode("n1", false), disruptNode("spare", false)},
+			replace:           true,
+			want:              DisruptResult{Mode: DisruptModeCordon, Nodes: []string{"n1"}, EvictedPods: 1, Uncordoned: []string{"n1"}},
+			wantUnschedulable: []string{},
+		},
+		{
+			name:              "kept cordoned",
+			opts:              DisruptOptions{Nodes: 1, Mode: DisruptModeCordon, KeepCordoned: true},
+			objects:           []runtime.Object{webDeployment(1), disruptPod("web-a", "n1", nil), disruptNode("n1", false), disruptNode("spare", false)},
+			replace:           true,
+			want:              DisruptResult{Mode: DisruptModeCordon, Nodes: []string{"n1"}, EvictedPods: 1},
+			wantUnschedulable: []string{"n1"},
+		},
+		{
+			name: "a failed delete restores the nodes that were neither deleted nor already cordoned",
+			opts: DisruptOptions{Nodes: 3, Mode: DisruptModeDelete},
+			objects: []runtime.Object{
+				webDeployment(3),
+				disruptPod("web-a", "n1", nil), disruptPod("web-b", "n2", nil), disruptPod("web-c", "n3", nil),
+				disruptNode("n1", false), disruptNode("n2", true), disruptNode("n3", false),
+			},
+			forbidden:         "n2",
+			want:              DisruptResult{Mode: DisruptModeDelete, Nodes: []string{"n1", "n2", "n3"}, EvictedPods: 2, Uncordoned: []string{"n3"}},
+			wantErr:           true,
+			wantUnschedulable: []string{"n2"},
+			wantDeleted:       []string{"n1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			clientset := fake.NewSimpleClientset(tc.objects...)
+			podsByNode(clientset)
+			evictions(clientset, func(name string) error {
+				if !tc.replace {
+					return nil
+				}
+				replacement := disruptPod(name+"-replacement", "spare", nil)
+				return clientset.Tracker().Create(podsResource, replacement, replacement.Namespace)
+			})
+			clientset.PrependReactor("delete", "nodes", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				if name := action.(k8stesting.DeleteAction).GetName(); name == tc.forbidden {
+					return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "nodes"}, name, errors.New("denied"))
+				}
+				return false, nil, nil
+			})
+			opts := tc.opts
+			opts.Filters = ListFilters{Namespace: "inflate"}
+			opts.Timeout = 10 * time.Second
+
+			result, err := New(clientset).Disrupt(context.Background(), opts)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Disrupt() error = %v, want error %t", err, tc.wantErr)
+			}
+			result.TimeToReady = 0
+			if !reflect.DeepEqual(result, tc.want) {
+				t.Errorf("Disrupt() = %+v, want %+v", result, tc.want)
+			}
+			nodeList, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			unschedulable := lo.FilterMap(nodeList.Items, func(node corev1.Node, _ int) (string, bool) { return node.Name, node.Spec.Unschedulable })
+			if !reflect.DeepEqual(unschedulable, tc.wantUnschedulable) {
+				t.Errorf("unschedulable nodes = %v, want %v", unschedulable, tc.wantUnschedulable)
+			}
+			for _, name := range tc.wantDeleted {
+				if lo.ContainsBy(nodeList.Items, func(node corev1.Node) bool { return node.Name == name }) {
+					t.Errorf("node %s was not deleted", name)
+				}
+			}
+		})
+	}
+}
+
+func TestRecovered(t *testing.T) {
+	terminating := disruptPod("web-terminating", "n2", nil)
+	terminating.DeletionTimestamp = lo.ToPtr(metav1.Now())
+	terminating.Finalizers = []string{"test"}
+	notReady := disruptPod("web-not-ready", "n2", nil)
+	notReady.Status.Conditions = nil
+	for _, tc := range []struct {
+		name    string
+		objects []runtime.Object
+		want    bool
+		wantErr bool
+	}{
+		{
+			name:    "ready on other nodes",
+			objects: []runtime.Object{webDeployment(2), disruptPod("web-a", "n2", nil), disruptPod("web-b", "n3", nil)},
+			want:    true,
+		},
+		{
+			name:    "pods on the disrupted node do not count",
+			objects: []runtime.Object{webDeployment(2), disruptPod("web-a", "n1", nil), disruptPod("web-b", "n2", nil)},
+		},
+		{
+			name:    "terminating and unready pods do not count",
+			objects: []runtime.Object{webDeployment(2), disruptPod("web-a", "n2", nil), terminating, notReady},
+		},
+		{
+			name:    "pods of other inflates do not count",
+			objects: []runtime.Object{webDeployment(2), disruptPod("web-a", "n2", nil), disruptPod("cache-a", "n2", map[string]string{"app": "cache", "managed-by": "inflate"})},
+		},
+		{name: "deleted deployment", objects: []runtime.Object{}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := New(fake.NewSimpleClientset(tc.objects...)).recovered(context.Background(), []appsv1.Deployment{*webDeployment(2)}, []string{"n1"})
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("recovered() error = %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("recovered() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
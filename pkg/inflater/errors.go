@@ -0,0 +1,90 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"fmt"
+	"time"
+
+	"go.uber.org/multierr"
+)
+
+// NotFoundError is returned when no inflate matches the name or namespace
+type NotFoundError struct {
+	Namespace string
+	Name      string
+}
+
+func (e *NotFoundError) Error() string {
+	switch {
+	case e.Name != "" && e.Namespace != "":
+		return fmt.Sprintf("inflate %q not found in namespace %q", e.Name, e.Namespace)
+	case e.Name != "":
+		return fmt.Sprintf("inflate %q not found in any inflate namespace", e.Name)
+	case e.Namespace != "":
+		return fmt.Sprintf("no inflates found in namespace %q", e.Namespace)
+	default:
+		return "no inflates found"
+	}
+}
+
+// ValidationError is returned for invalid options before any change is made
+type ValidationError struct {
+	Err error
+}
+
+// NewValidationError formats a ValidationError
+func NewValidationError(format string, args ...any) error {
+	return &ValidationError{Err: fmt.Errorf(format, args...)}
+}
+
+func (e *ValidationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+// PartialError is returned when an operation failed for some of the objects, namespaces or clusters but not for all of them
+type PartialError struct {
+	Errs []error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("partially failed, %s", multierr.Combine(e.Errs...))
+}
+
+func (e *PartialError) Unwrap() []error {
+	return e.Errs
+}
+
+// TimeoutError is returned when an operation or the wait for its outcome did not finish in time
+type TimeoutError struct {
+	Operation string
+	Timeout   time.Duration
+	Err       error
+}
+
+func (e *TimeoutError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("%s timed out after %s", e.Operation, e.Timeout)
+	}
+	return fmt.Sprintf("%s timed out after %s, %s", e.Operation, e.Timeout, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
@@ -19,17 +19,35 @@ import (
 	"fmt"
 	"math/rand"
 
+	"github.com/go-logr/logr"
 	"github.com/imdario/mergo"
 	"github.com/samber/lo"
 	"go.uber.org/multierr"
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
+	schedulingv1 "k8s.io/api/scheduling/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 	"k8s.io/client-go/kubernetes"
 )
 
+const (
+	DefaultImage = "public.ecr.aws/eks-distro/kubernetes/pause:3.7"
+	// DefaultLoadImage runs the busy loop of pods with a CPU load, it needs a shell with nproc and seq
+	DefaultLoadImage = "public.ecr.aws/docker/library/busybox:stable"
+
+	LabelNodePool          = "karpenter.sh/nodepool"
+	LabelCapacityType      = "karpenter.sh/capacity-type"
+	LabelInstanceFamily    = "karpenter.k8s.aws/instance-family"
+	AnnotationDoNotDisrupt = "karpenter.sh/do-not-disrupt"
+	// AnnotationProtect on a deployment makes Delete skip the inflate unless forced
+	AnnotationProtect = "inflate/protect"
+)
+
 var (
 	DefaultOptions = Options{
 		Namespace:   "inflate",
@@ -38,34 +56,100 @@ var (
 )
 
 type Options struct {
+	Name               string
 	RandomSuffix       bool
 	Namespace          string
 	Image              string
 	ZonalSpread        bool
 	HostnameSpread     bool
 	CapacityTypeSpread bool
-	HostNetwork        bool
-	CPUArch            string
-	OS                 string
-	Service            bool
-	DryRun             bool
+	// AntiAffinity is the topology key of a required pod anti-affinity that keeps the inflate's pods in separate domains
+	AntiAffinity string
+	// Tolerations let the pods schedule onto tainted nodes
+	Tolerations []corev1.Toleration
+	HostNetwork bool
+	CPUArch     string
+	OS          string
+	// NodePool, CapacityType, InstanceType and InstanceFamily select nodes by their Karpenter labels
+	NodePool       string
+	CapacityType   string
+	InstanceType   string
+	InstanceFamily string
+	// DoNotDisrupt keeps Karpenter from voluntarily disrupting the nodes running inflate pods
+	DoNotDisrupt bool
+	// Protect keeps Delete from deleting the inflate unless forced
+	Protect           bool
+	Service           bool
+	DryRun            bool
+	PriorityClassName string
+	// PriorityClassValue creates a managed PriorityClass with this value when set
+	PriorityClassValue *int32
+	PreemptionPolicy   string
+	// ForceConflicts takes ownership of fields managed by other field managers when applying
+	ForceConflicts bool
+	// HPA creates a HorizontalPodAutoscaler for the deployment, which then no longer sets replicas
+	HPA *HPAOptions
+	// LoadCPU swaps the container for a busy loop of LoadImage that uses this much CPU
+	LoadCPU   *resource.Quantity
+	LoadImage string
+	// Stress swaps the container for StressScript run by StressImage
+	Stress      *StressOptions
+	StressImage string
+}
+
+// HPAOptions configure the autoscaling/v2 HorizontalPodAutoscaler of an inflate
+type HPAOptions struct {
+	MinReplicas int32
+	MaxReplicas int32
+	// TargetCPUUtilization is the average CPU utilization percentage of the requests to scale to
+	TargetCPUUtilization int32
 }
 
 type InflateCollection struct {
-	Deployment *appsv1.Deployment
-	Service    *corev1.Service
+	Deployment              *appsv1.Deployment
+	Service                 *corev1.Service
+	PriorityClass           *schedulingv1.PriorityClass
+	HorizontalPodAutoscaler *autoscalingv2.HorizontalPodAutoscaler
+	// Results describes what applying each object did, it is empty on a dry-run
+	Results []ApplyResult
+}
+
+// Objects returns the objects of the collection in the order they should be applied
+func (c InflateCollection) Objects() []runtime.Object {
+	var objects []runtime.Object
+	if c.PriorityClass != nil {
+		objects = append(objects, c.PriorityClass)
+	}
+	if c.Deployment != nil {
+		objects = append(objects, c.Deployment)
+	}
+	if c.Service != nil {
+		objects = append(objects, c.Service)
+	}
+	if c.HorizontalPodAutoscaler != nil {
+		objects = append(objects, c.HorizontalPodAutoscaler)
+	}
+	return objects
 }
 
 type Inflater struct {
-	clientset *kubernetes.Clientset
+	clientset kubernetes.Interface
+	logger    logr.Logger
 }
 
-func New(clientset *kubernetes.Clientset) *Inflater {
+func New(clientset kubernetes.Interface) *Inflater {
 	return &Inflater{
 		clientset: clientset,
+		logger:    logr.Discard(),
 	}
 }
 
+// WithLogger sets the logger used for the objects applied and deleted and the retried requests
+func (i *Inflater) WithLogger(logger logr.Logger) *Inflater {
+	i.logger = logger
+	return i
+}
+
 func GetDefaultOptions() Options {
 	return Options{
 		Namespace:   "inflate",
@@ -73,15 +157,26 @@ func GetDefaultOptions() Options {
 	}
 }
 
-func (i Inflater) CreateNamespace(ctx context.Context, namespace string) error {
-	_, err := i.clientset.CoreV1().Namespaces().Create(ctx, &corev1.Namespace{
+func (i Inflater) GetNamespace(namespace string) *corev1.Namespace {
+	return &corev1.Namespace{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Namespace",
+		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name: namespace,
 			Labels: map[string]string{
 				"managed-by": "inflate",
 			},
 		},
-	}, metav1.CreateOptions{})
+	}
+}
+
+func (i Inflater) CreateNamespace(ctx context.Context, namespace string) error {
+	err := withRetry(i.logger, func() error {
+		_, err := i.clientset.CoreV1().Namespaces().Create(ctx, i.GetNamespace(namespace), metav1.CreateOptions{})
+		return err
+	})
 	if errors.IsAlreadyExists(err) {
 		return nil
 	}
@@ -89,7 +184,7 @@ func (i Inflater) CreateNamespace(ctx context.Context, namespace string) error {
 }
 
 func getName(opts Options) string {
-	appName := "inflate"
+	appName := lo.Ternary(opts.Name != "", opts.Name, "inflate")
 	if opts.RandomSuffix {
 		//nolint:gosec
 		appName += fmt.Sprintf("-%d", rand.Intn(9_999_999_999))
@@ -103,34 +198,36 @@ func (i Inflater) GetInflateDeployment(_ context.Context, opts Options) (*appsv1
 		return nil, err
 	}
 	appName := getName(opts)
+	objectMeta := i.objectMeta(opts.Namespace, appName)
+	if opts.Protect {
+		objectMeta.Annotations = map[string]string{AnnotationProtect: "true"}
+	}
 	return &appsv1.Deployment{
-		ObjectMeta: i.objectMeta(opts.Namespace, appName),
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "Deployment",
+		},
+		ObjectMeta: objectMeta,
 		Spec: appsv1.DeploymentSpec{
-			Replicas: lo.ToPtr(int32(1)),
+			// replicas are left to the HPA when there is one
+			Replicas: lo.Ternary(opts.HPA == nil, lo.ToPtr(int32(1)), nil),
 			Selector: &metav1.LabelSelector{
 				MatchLabels: i.defaultLabels(appName),
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: i.defaultLabels(appName),
+					Labels:      i.defaultLabels(appName),
+					Annotations: i.podAnnotations(opts),
 				},
 				Spec: corev1.PodSpec{
 					HostNetwork:                   opts.HostNetwork,
+					PriorityClassName:             i.priorityClassName(opts, appName),
 					TerminationGracePeriodSeconds: lo.ToPtr(int64(0)),
-					Containers: []corev1.Container{
-						{
-							Name:  appName,
-							Image: opts.Image,
-							Resources: corev1.ResourceRequirements{
-								Requests: corev1.ResourceList{
-									corev1.ResourceCPU:    *resource.NewQuantity(1, resource.DecimalSI),
-									corev1.ResourceMemory: *resource.NewQuantity(256, resource.BinarySI),
-								},
-							},
-						},
-					},
-					TopologySpreadConstraints: i.topologySpread(opts, i.defaultLabels(appName)),
-					NodeSelector:              i.nodeSelector(opts),
+					Containers:                    []corev1.Container{i.container(opts, appName)},
+					TopologySpreadConstraints:     i.topologySpread(opts, i.defaultLabels(appName)),
+					NodeSelector:                  i.nodeSelector(opts),
+					Affinity:                      i.affinity(opts, i.defaultLabels(appName)),
+					Tolerations:                   opts.Tolerations,
 				},
 			},
 		},
@@ -143,6 +240,10 @@ func (i Inflater) GetService(_ context.Context, appName string, opts Options) (*
 		return nil, err
 	}
 	return &corev1.Service{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: corev1.SchemeGroupVersion.String(),
+			Kind:       "Service",
+		},
 		ObjectMeta: i.objectMeta(opts.Namespace, appName),
 		Spec: corev1.ServiceSpec{
 			Selector: map[string]string{
@@ -150,13 +251,76 @@ func (i Inflater) GetService(_ context.Context, appName string, opts Options) (*
 			},
 			Ports: []corev1.ServicePort{
 				{
-					Port: 8080,
+					Port:       8080,
+					TargetPort: intstr.FromInt(8080),
+				},
+			},
+		},
+	}, nil
+}
+
+func (i Inflater) GetHorizontalPodAutoscaler(_ context.Context, appName string, opts Options) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	opts, err := mergeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.HPA == nil {
+		return nil, NewValidationError("hpa options are required to create the horizontal pod autoscaler %s", appName)
+	}
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: autoscalingv2.SchemeGroupVersion.String(),
+			Kind:       "HorizontalPodAutoscaler",
+		},
+		ObjectMeta: i.objectMeta(opts.Namespace, appName),
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: autoscalingv2.CrossVersionObjectReference{
+				APIVersion: appsv1.SchemeGroupVersion.String(),
+				Kind:       "Deployment",
+				Name:       appName,
+			},
+			MinReplicas: lo.ToPtr(opts.HPA.MinReplicas),
+			MaxReplicas: opts.HPA.MaxReplicas,
+			Metrics: []autoscalingv2.MetricSpec{
+				{
+					Type: autoscalingv2.ResourceMetricSourceType,
+					Resource: &autoscalingv2.ResourceMetricSource{
+						Name: corev1.ResourceCPU,
+						Target: autoscalingv2.MetricTarget{
+							Type:               autoscalingv2.UtilizationMetricType,
+							AverageUtilization: lo.ToPtr(opts.HPA.TargetCPUUtilization),
+						},
+					},
 				},
 			},
 		},
 	}, nil
 }
 
+func (i Inflater) GetPriorityClass(_ context.Context, name string, opts Options) (*schedulingv1.PriorityClass, error) {
+	opts, err := mergeOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	if opts.PriorityClassValue == nil {
+		return nil, NewValidationError("a priority class value is required to create priority class %s", name)
+	}
+	var preemptionPolicy *corev1.PreemptionPolicy
+	if opts.PreemptionPolicy != "" {
+		preemptionPolicy = lo.ToPtr(corev1.PreemptionPolicy(opts.PreemptionPolicy))
+	}
+	return &schedulingv1.PriorityClass{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: schedulingv1.SchemeGroupVersion.String(),
+			Kind:       "PriorityClass",
+		},
+		ObjectMeta:       i.objectMeta("", name),
+		Value:            *opts.PriorityClassValue,
+		PreemptionPolicy: preemptionPolicy,
+		Description:      "managed by inflate",
+	}, nil
+}
+
 func (i Inflater) Inflate(ctx context.Context, opts Options) (*InflateCollection, error) {
 	opts, err := mergeOptions(opts)
 	if err != nil {
@@ -173,45 +337,62 @@ func (i Inflater) Inflate(ctx context.Context, opts Options) (*InflateCollection
 	if err != nil {
 		return nil, err
 	}
-
+	inflateCollection.Deployment = deployment
+	if opts.PriorityClassValue != nil {
+		inflateCollection.PriorityClass, err = i.GetPriorityClass(ctx, deployment.Spec.Template.Spec.PriorityClassName, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.Service {
+		inflateCollection.Service, err = i.GetService(ctx, deployment.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if opts.HPA != nil {
+		inflateCollection.HorizontalPodAutoscaler, err = i.GetHorizontalPodAutoscaler(ctx, deployment.Name, opts)
+		if err != nil {
+			return nil, err
+		}
+	}
 	if opts.DryRun {
-		inflateCollection.Deployment = deployment
-	} else {
-		deploymentFromAPI, err := i.clientset.AppsV1().Deployments(opts.Namespace).Create(ctx, deployment, metav1.CreateOptions{})
+		return inflateCollection, nil
+	}
+
+	// the priority class must exist before the deployment's pods can be admitted
+	if inflateCollection.PriorityClass != nil {
+		priorityClass, result, err := apply[*schedulingv1.PriorityClass](ctx, i.logger, i.clientset.SchedulingV1().PriorityClasses(), inflateCollection.PriorityClass, opts.ForceConflicts)
 		if err != nil {
-			if errors.IsAlreadyExists(err) {
-				deploymentFromAPI, err = i.clientset.AppsV1().Deployments(opts.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
-				if err != nil {
-					return inflateCollection, err
-				}
-			} else {
-				return nil, err
-			}
+			return inflateCollection, err
 		}
-		inflateCollection.Deployment = deploymentFromAPI
+		inflateCollection.PriorityClass = priorityClass
+		inflateCollection.Results = append(inflateCollection.Results, result)
 	}
-	service, err := i.GetService(ctx, inflateCollection.Deployment.Name, opts)
+	deployment, result, err := apply[*appsv1.Deployment](ctx, i.logger, i.clientset.AppsV1().Deployments(opts.Namespace), deployment, opts.ForceConflicts)
 	if err != nil {
-		return nil, err
+		return inflateCollection, err
 	}
-
-	if opts.DryRun {
+	inflateCollection.Deployment = deployment
+	inflateCollection.Results = append(inflateCollection.Results, result)
+	if inflateCollection.Service != nil {
+		service, result, err := apply[*corev1.Service](ctx, i.logger, i.clientset.CoreV1().Services(opts.Namespace), inflateCollection.Service, opts.ForceConflicts)
+		if err != nil {
+			return inflateCollection, err
+		}
 		inflateCollection.Service = service
-	} else {
-		serviceFromAPI, err := i.clientset.CoreV1().Services(opts.Namespace).Create(ctx, service, metav1.CreateOptions{})
+		inflateCollection.Results = append(inflateCollection.Results, result)
+	}
+	if inflateCollection.HorizontalPodAutoscaler != nil {
+		hpa, result, err := apply[*autoscalingv2.HorizontalPodAutoscaler](ctx, i.logger, i.clientset.AutoscalingV2().HorizontalPodAutoscalers(opts.Namespace),
+			inflateCollection.HorizontalPodAutoscaler, opts.ForceConflicts)
 		if err != nil {
-			if errors.IsAlreadyExists(err) {
-				serviceFromAPI, err = i.clientset.CoreV1().Services(opts.Namespace).Update(ctx, service, metav1.UpdateOptions{})
-				if err != nil {
-					return inflateCollection, err
-				}
-			} else {
-				return inflateCollection, err
-			}
+			return inflateCollection, err
 		}
-		inflateCollection.Service = serviceFromAPI
+		inflateCollection.HorizontalPodAutoscaler = hpa
+		inflateCollection.Results = append(inflateCollection.Results, result)
 	}
-	return inflateCollection, err
+	return inflateCollection, nil
 }
 
 type ListFilters struct {
@@ -219,92 +400,93 @@ type ListFilters struct {
 	Name      string
 }
 
+// List returns the inflate deployments matching the filters, namespaces without a match are skipped.
+// If listing some of the namespaces fails, the deployments found in the others are returned with a PartialError.
 func (i Inflater) List(ctx context.Context, filters ListFilters) ([]appsv1.Deployment, error) {
-	var namespaces []string
+	namespaces, err := i.managedNamespaces(ctx, filters.Namespace)
+	if err != nil {
+		return nil, err
+	}
 	var deployments []appsv1.Deployment
-	if filters.Namespace != "" {
-		namespaces = append(namespaces, filters.Namespace)
-	} else {
-		namespaceList, err := i.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
-			LabelSelector: "managed-by=inflate",
+	var errs []error
+	for _, ns := range namespaces {
+		deploymentList, err := i.clientset.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{
+			LabelSelector: inflateSelector(filters.Name),
 		})
 		if err != nil {
-			return nil, err
-		}
-		namespaces = lo.Map(namespaceList.Items, func(ns corev1.Namespace, _ int) string { return ns.Name })
-	}
-	if filters.Name != "" {
-		for _, ns := range namespaces {
-			deployment, err := i.clientset.AppsV1().Deployments(ns).Get(ctx, filters.Name, metav1.GetOptions{})
-			if err != nil {
-				return nil, err
-			}
-			deployments = append(deployments, *deployment)
+			errs = append(errs, fmt.Errorf("listing deployments in namespace %s, %w", ns, err))
+			continue
 		}
+		deployments = append(deployments, deploymentList.Items...)
+	}
+	switch {
+	case len(errs) == 0:
 		return deployments, nil
+	case len(errs) < len(namespaces):
+		return deployments, &PartialError{Errs: errs}
+	default:
+		return deployments, multierr.Combine(errs...)
 	}
+}
 
-	var errs error
-	for _, ns := range namespaces {
-		deploymentList, err := i.clientset.AppsV1().Deployments(ns).List(ctx, metav1.ListOptions{
-			LabelSelector: "managed-by=inflate",
-		})
-		errs = multierr.Append(errs, err)
-		deployments = append(deployments, deploymentList.Items...)
+// managedNamespaces returns the namespace, or every inflate managed namespace if it is empty
+func (i Inflater) managedNamespaces(ctx context.Context, namespace string) ([]string, error) {
+	if namespace != "" {
+		return []string{namespace}, nil
+	}
+	namespaceList, err := i.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
+		LabelSelector: "managed-by=inflate",
+	})
+	if err != nil {
+		return nil, err
 	}
-	return deployments, errs
+	return lo.Map(namespaceList.Items, func(ns corev1.Namespace, _ int) string { return ns.Name }), nil
 }
 
-type DeleteFilters struct {
-	Namespace string
-	Name      string
+// inflateSelector selects the objects of the inflate with the name, or of every inflate if the name is empty
+func inflateSelector(name string) string {
+	if name == "" {
+		return "managed-by=inflate"
+	}
+	return fmt.Sprintf("app=%s,managed-by=inflate", name)
 }
 
-func (i Inflater) Delete(ctx context.Context, filters DeleteFilters) error {
-	var namespaces []string
-	if filters.Namespace != "" {
-		namespaces = append(namespaces, filters.Namespace)
-	} else {
-		namespaceList, err := i.clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{
-			LabelSelector: "managed-by=inflate",
-		})
-		if err != nil {
-			return err
-		}
-		namespaces = lo.Map(namespaceList.Items, func(ns corev1.Namespace, _ int) string { return ns.Name })
-	}
-	if filters.Name != "" {
-		for _, ns := range namespaces {
-			if err := i.clientset.AppsV1().Deployments(ns).Delete(ctx, filters.Name, metav1.DeleteOptions{}); err != nil {
-				return err
-			}
-			if err := i.clientset.CoreV1().Services(ns).Delete(ctx, filters.Name, metav1.DeleteOptions{}); err != nil {
-				return err
-			}
-		}
-		return nil
+// container runs the pause image, the stress script, or a busy loop limited to the CPU load
+func (i Inflater) container(opts Options, appName string) corev1.Container {
+	container := corev1.Container{
+		Name:  appName,
+		Image: opts.Image,
+		Resources: corev1.ResourceRequirements{
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU:    *resource.NewQuantity(1, resource.DecimalSI),
+				corev1.ResourceMemory: *resource.NewQuantity(256, resource.BinarySI),
+			},
+		},
 	}
-
-	var errs error
-	for _, ns := range namespaces {
-		if err := i.clientset.AppsV1().Deployments(ns).DeleteCollection(ctx, metav1.DeleteOptions{}, metav1.ListOptions{
-			LabelSelector: "managed-by=inflate",
-		}); err != nil {
-			errs = multierr.Append(errs, err)
+	if opts.Stress != nil {
+		container.Image = lo.Ternary(opts.StressImage != "", opts.StressImage, DefaultStressImage)
+		container.Command = []string{"python3"}
+		container.Args = StressArgs(*opts.Stress)
+		if opts.Stress.CPU > 0 {
+			container.Resources.Requests[corev1.ResourceCPU] = *resource.NewQuantity(int64(opts.Stress.CPU), resource.DecimalSI)
 		}
-		services, err := i.clientset.CoreV1().Services(ns).List(ctx, metav1.ListOptions{
-			LabelSelector: "managed-by=inflate",
-		})
-		if err != nil {
-			errs = multierr.Append(errs, err)
+		if opts.Stress.Memory != nil {
+			container.Resources.Requests[corev1.ResourceMemory] = *opts.Stress.Memory
 		}
-		for _, service := range services.Items {
-			if err := i.clientset.CoreV1().Services(ns).Delete(ctx, service.Name, metav1.DeleteOptions{}); err != nil {
-				errs = multierr.Append(errs, err)
-			}
+		if opts.Stress.MemoryLimit != nil {
+			container.Resources.Limits = corev1.ResourceList{corev1.ResourceMemory: *opts.Stress.MemoryLimit}
 		}
+		return container
+	}
+	if opts.LoadCPU == nil {
+		return container
 	}
-	return errs
+	container.Image = lo.Ternary(opts.LoadImage != "", opts.LoadImage, DefaultLoadImage)
+	// one loop per visible cpu, the cpu limit throttles them down to the load
+	container.Command = []string{"sh", "-c", "for i in $(seq $(nproc)); do while :; do :; done & done; wait"}
+	container.Resources.Requests[corev1.ResourceCPU] = *opts.LoadCPU
+	container.Resources.Limits = corev1.ResourceList{corev1.ResourceCPU: *opts.LoadCPU}
+	return container
 }
 
 func (i Inflater) nodeSelector(opts Options) map[string]string {
@@ -315,9 +497,37 @@ func (i Inflater) nodeSelector(opts Options) map[string]string {
 	if opts.OS != "" {
 		nodeSelector["kubernetes.io/os"] = opts.OS
 	}
+	if opts.NodePool != "" {
+		nodeSelector[LabelNodePool] = opts.NodePool
+	}
+	if opts.CapacityType != "" {
+		nodeSelector[LabelCapacityType] = opts.CapacityType
+	}
+	if opts.InstanceType != "" {
+		nodeSelector[corev1.LabelInstanceTypeStable] = opts.InstanceType
+	}
+	if opts.InstanceFamily != "" {
+		nodeSelector[LabelInstanceFamily] = opts.InstanceFamily
+	}
 	return lo.Ternary(len(nodeSelector) == 0, nil, nodeSelector)
 }
 
+func (i Inflater) podAnnotations(opts Options) map[string]string {
+	if !opts.DoNotDisrupt {
+		return nil
+	}
+	return map[string]string{AnnotationDoNotDisrupt: "true"}
+}
+
+// priorityClassName returns the PriorityClass the inflate pods should use.
+// A managed PriorityClass without an explicit name is named after the inflate.
+func (i Inflater) priorityClassName(opts Options, appName string) string {
+	if opts.PriorityClassName != "" {
+		return opts.PriorityClassName
+	}
+	return lo.Ternary(opts.PriorityClassValue != nil, appName, "")
+}
+
 func (i Inflater) topologySpread(opts Options, matchLabels map[string]string) []corev1.TopologySpreadConstraint {
 	var topologySpreadConstraints []corev1.TopologySpreadConstraint
 	if opts.ZonalSpread {
@@ -343,7 +553,7 @@ func (i Inflater) topologySpread(opts Options, matchLabels map[string]string) []
 	if opts.CapacityTypeSpread {
 		topologySpreadConstraints = append(topologySpreadConstraints, corev1.TopologySpreadConstraint{
 			MaxSkew:           int32(1),
-			TopologyKey:       "karpenter.sh/capacity-type",
+			TopologyKey:       LabelCapacityType,
 			WhenUnsatisfiable: "DoNotSchedule",
 			LabelSelector: &metav1.LabelSelector{
 				MatchLabels: matchLabels,
@@ -353,6 +563,22 @@ func (i Inflater) topologySpread(opts Options, matchLabels map[string]string) []
 	return topologySpreadConstraints
 }
 
+func (i Inflater) affinity(opts Options, matchLabels map[string]string) *corev1.Affinity {
+	if opts.AntiAffinity == "" {
+		return nil
+	}
+	return &corev1.Affinity{
+		PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+				LabelSelector: &metav1.LabelSelector{
+					MatchLabels: matchLabels,
+				},
+				TopologyKey: opts.AntiAffinity,
+			}},
+		},
+	}
+}
+
 func (i Inflater) objectMeta(namespace string, name string) metav1.ObjectMeta {
 	return metav1.ObjectMeta{
 		Name:      name,
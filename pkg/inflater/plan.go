@@ -0,0 +1,414 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// PlanOptions describe the inflate to plan and how many replicas it should run
+type PlanOptions struct {
+	Options  Options
+	Replicas int32
+}
+
+// Fit is how many replicas fit in a ResourceQuota or on a node, Limit is the resource that runs out first
+type Fit struct {
+	Name     string
+	Replicas int64
+	Limit    string
+}
+
+// Plan tells whether the replicas of an inflate fit in the quotas of its namespace and on the current nodes
+type Plan struct {
+	Namespace string
+	Name      string
+	Replicas  int32
+	// PodRequests and PodLimits are those of a single pod after the LimitRange defaults are applied
+	PodRequests   corev1.ResourceList
+	PodLimits     corev1.ResourceList
+	TotalRequests corev1.ResourceList
+	// Problems are LimitRange and ResourceQuota violations that reject every pod
+	Problems []string
+	// Quotas are the ResourceQuotas of the namespace, QuotaReplicas is the number of replicas all of them admit, nil without quotas
+	Quotas        []Fit
+	QuotaReplicas *int64
+	TotalNodes    int
+	// Nodes are the schedulable nodes matching the node selector whose taints are tolerated
+	Nodes []Fit
+	// NodeReplicas is the number of replicas the matching nodes can run, topology spread constraints included
+	NodeReplicas int64
+	// FitNow replicas can run on the current nodes, NeedNewNodes are admitted by the quotas but do not fit
+	// and QuotaBlocked are rejected by the quotas or a LimitRange
+	FitNow       int64
+	NeedNewNodes int64
+	QuotaBlocked int64
+}
+
+// Plan checks the requests of the replicas of an inflate against the ResourceQuotas and LimitRanges of its namespace
+// and against the allocatable capacity the matching nodes have left. It assumes none of the inflate's pods are running yet.
+func (i Inflater) Plan(ctx context.Context, opts PlanOptions) (*Plan, error) {
+	deployment, err := i.GetInflateDeployment(ctx, opts.Options)
+	if err != nil {
+		return nil, err
+	}
+	plan := &Plan{Namespace: deployment.Namespace, Name: deployment.Name, Replicas: opts.Replicas}
+	podSpec := deployment.Spec.Template.Spec.DeepCopy()
+
+	limitRangeList, err := i.clientset.CoreV1().LimitRanges(plan.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing limit ranges in namespace %s, %w", plan.Namespace, err)
+	}
+	for _, limitRange := range limitRangeList.Items {
+		applyLimitRangeDefaults(podSpec, limitRange)
+	}
+	plan.PodRequests, plan.PodLimits = PodRequests(*podSpec), podLimits(*podSpec)
+	plan.TotalRequests = lo.MapValues(plan.PodRequests, func(quantity resource.Quantity, _ corev1.ResourceName) resource.Quantity {
+		return multiply(quantity, int64(opts.Replicas))
+	})
+	for _, limitRange := range limitRangeList.Items {
+		plan.Problems = append(plan.Problems, limitRangeViolations(*podSpec, limitRange)...)
+	}
+
+	quotaList, err := i.clientset.CoreV1().ResourceQuotas(plan.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing resource quotas in namespace %s, %w", plan.Namespace, err)
+	}
+	var creates []corev1.ResourceName
+	if len(quotaList.Items) > 0 {
+		// re-planning an existing inflate does not create its deployment or service again
+		if creates, err = i.createdObjects(ctx, plan.Namespace, plan.Name, opts.Options.Service); err != nil {
+			return nil, err
+		}
+	}
+	for _, quota := range quotaList.Items {
+		fit, problems := quotaFit(quota, plan.PodRequests, plan.PodLimits, creates)
+		plan.Problems = append(plan.Problems, problems...)
+		if fit == nil {
+			continue
+		}
+		plan.Quotas = append(plan.Quotas, *fit)
+		if plan.QuotaReplicas == nil || fit.Replicas < *plan.QuotaReplicas {
+			plan.QuotaReplicas = lo.ToPtr(fit.Replicas)
+		}
+	}
+
+	nodeList, err := i.clientset.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("listing nodes, %w", err)
+	}
+	podList, err := i.clientset.CoreV1().Pods("").List(ctx, metav1.ListOptions{FieldSelector: "status.phase!=Succeeded,status.phase!=Failed"})
+	if err != nil {
+		return nil, fmt.Errorf("listing pods, %w", err)
+	}
+	// the pods resource counts the pods on a node against its allocatable pods
+	requested := map[string]corev1.ResourceList{}
+	for _, pod := range podList.Items {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		requested[pod.Spec.NodeName] = addResources(requested[pod.Spec.NodeName], podResources(pod.Spec), 1)
+	}
+	plan.TotalNodes = len(nodeList.Items)
+	var nodes []corev1.Node
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable || !MatchesNode(*podSpec, node) {
+			continue
+		}
+		nodes = append(nodes, node)
+		replicas, limit := fitInto(subtractResources(node.Status.Allocatable, requested[node.Name]), podResources(*podSpec))
+		plan.Nodes = append(plan.Nodes, Fit{Name: node.Name, Replicas: replicas, Limit: limit})
+	}
+	sort.Slice(plan.Nodes, func(a, b int) bool { return plan.Nodes[a].Replicas > plan.Nodes[b].Replicas })
+	plan.NodeReplicas = spreadReplicas(podSpec.TopologySpreadConstraints, nodes, lo.SliceToMap(plan.Nodes, func(fit Fit) (string, int64) {
+		return fit.Name, fit.Replicas
+	}))
+
+	admitted := int64(opts.Replicas)
+	if len(plan.Problems) > 0 {
+		admitted = 0
+	} else if plan.QuotaReplicas != nil && *plan.QuotaReplicas < admitted {
+		admitted = *plan.QuotaReplicas
+	}
+	plan.QuotaBlocked = int64(opts.Replicas) - admitted
+	plan.FitNow = lo.Min([]int64{admitted, plan.NodeReplicas})
+	plan.NeedNewNodes = admitted - plan.FitNow
+	return plan, nil
+}
+
+// createdObjects returns the object count quota resources of the deployment and service that creating the inflate would add
+func (i Inflater) createdObjects(ctx context.Context, namespace, name string, service bool) ([]corev1.ResourceName, error) {
+	var creates []corev1.ResourceName
+	if _, err := i.clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{}); errors.IsNotFound(err) {
+		creates = append(creates, "count/deployments.apps")
+	} else if err != nil {
+		return nil, fmt.Errorf("getting deployment %s/%s, %w", namespace, name, err)
+	}
+	if !service {
+		return creates, nil
+	}
+	if _, err := i.clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{}); errors.IsNotFound(err) {
+		creates = append(creates, corev1.ResourceServices, "count/services")
+	} else if err != nil {
+		return nil, fmt.Errorf("getting service %s/%s, %w", namespace, name, err)
+	}
+	return creates, nil
+}
+
+// PodRequests are the requests the scheduler accounts for a pod, the larger of its containers and any init container plus the overhead
+func PodRequests(podSpec corev1.PodSpec) corev1.ResourceList {
+	requests := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		requests = addResources(requests, container.Resources.Requests, 1)
+	}
+	for _, container := range podSpec.InitContainers {
+		for name, quantity := range container.Resources.Requests {
+			if current, ok := requests[name]; !ok || quantity.Cmp(current) > 0 {
+				requests[name] = quantity.DeepCopy()
+			}
+		}
+	}
+	return addResources(requests, podSpec.Overhead, 1)
+}
+
+// podResources are the requests of a pod and the pod itself
+func podResources(podSpec corev1.PodSpec) corev1.ResourceList {
+	return addResources(PodRequests(podSpec), corev1.ResourceList{corev1.ResourcePods: *resource.NewQuantity(1, resource.DecimalSI)}, 1)
+}
+
+func podLimits(podSpec corev1.PodSpec) corev1.ResourceList {
+	limits := corev1.ResourceList{}
+	for _, container := range podSpec.Containers {
+		limits = addResources(limits, container.Resources.Limits, 1)
+	}
+	return limits
+}
+
+// MatchesNode is true when the node has the labels of the pod's node selector and the pod tolerates the node's NoSchedule and NoExecute taints
+func MatchesNode(podSpec corev1.PodSpec, node corev1.Node) bool {
+	return matchesNodeSelector(podSpec, node) && toleratesTaints(podSpec, node)
+}
+
+func matchesNodeSelector(podSpec corev1.PodSpec, node corev1.Node) bool {
+	return labels.SelectorFromSet(podSpec.NodeSelector).Matches(labels.Set(node.Labels))
+}
+
+func toleratesTaints(podSpec corev1.PodSpec, node corev1.Node) bool {
+	return lo.EveryBy(node.Spec.Taints, func(taint corev1.Taint) bool {
+		return taint.Effect == corev1.TaintEffectPreferNoSchedule || lo.ContainsBy(podSpec.Tolerations, func(toleration corev1.Toleration) bool {
+			return toleration.ToleratesTaint(&taint)
+		})
+	})
+}
+
+// applyLimitRangeDefaults sets the default requests and limits of a LimitRange on containers that do not set them,
+// a container with a limit but no request requests its limit like the API server defaults it
+func applyLimitRangeDefaults(podSpec *corev1.PodSpec, limitRange corev1.LimitRange) {
+	for idx := range podSpec.Containers {
+		resources := &podSpec.Containers[idx].Resources
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for name, quantity := range item.Default {
+				if _, ok := resources.Limits[name]; !ok {
+					resources.Limits = addResources(resources.Limits, corev1.ResourceList{name: quantity}, 1)
+				}
+			}
+			for name, quantity := range item.DefaultRequest {
+				if _, ok := resources.Requests[name]; !ok {
+					resources.Requests = addResources(resources.Requests, corev1.ResourceList{name: quantity}, 1)
+				}
+			}
+		}
+		for name, quantity := range resources.Limits {
+			if _, ok := resources.Requests[name]; !ok {
+				resources.Requests = addResources(resources.Requests, corev1.ResourceList{name: quantity}, 1)
+			}
+		}
+	}
+}
+
+// limitRangeViolations checks the min and max of a LimitRange against the containers and the pod
+func limitRangeViolations(podSpec corev1.PodSpec, limitRange corev1.LimitRange) []string {
+	var problems []string
+	check := func(kind string, requests, limits corev1.ResourceList, item corev1.LimitRangeItem) {
+		for name, min := range item.Min {
+			if request, ok := requests[name]; ok && request.Cmp(min) < 0 {
+				problems = append(problems, fmt.Sprintf("limit range %s: %s %s request %s is below the minimum %s", limitRange.Name, kind, name, request.String(), min.String()))
+			}
+		}
+		for name, max := range item.Max {
+			limit, ok := limits[name]
+			switch {
+			case !ok:
+				problems = append(problems, fmt.Sprintf("limit range %s: %s needs a %s limit, the maximum is %s", limitRange.Name, kind, name, max.String()))
+			case limit.Cmp(max) > 0:
+				problems = append(problems, fmt.Sprintf("limit range %s: %s %s limit %s is above the maximum %s", limitRange.Name, kind, name, limit.String(), max.String()))
+			}
+		}
+	}
+	for _, item := range limitRange.Spec.Limits {
+		switch item.Type {
+		case corev1.LimitTypeContainer:
+			for _, container := range podSpec.Containers {
+				check("container", container.Resources.Requests, container.Resources.Limits, item)
+			}
+		case corev1.LimitTypePod:
+			check("pod", PodRequests(podSpec), podLimits(podSpec), item)
+		}
+	}
+	return problems
+}
+
+// quotaFit returns how many pods with the requests and limits fit in what is left of a ResourceQuota, or nil when the
+// quota limits none of the resources of the pods. The object counts in creates must have room for one more object.
+// Scoped quotas are treated as if they applied to the pods.
+func quotaFit(quota corev1.ResourceQuota, requests, limits corev1.ResourceList, creates []corev1.ResourceName) (*Fit, []string) {
+	var problems []string
+	perPod := corev1.ResourceList{}
+	for name := range quota.Spec.Hard {
+		var quantity resource.Quantity
+		var ok bool
+		var need string
+		switch name {
+		case corev1.ResourcePods, "count/pods":
+			quantity, ok = resource.MustParse("1"), true
+		case corev1.ResourceCPU, corev1.ResourceRequestsCPU:
+			quantity, ok = requests[corev1.ResourceCPU]
+			need = "cpu request"
+		case corev1.ResourceMemory, corev1.ResourceRequestsMemory:
+			quantity, ok = requests[corev1.ResourceMemory]
+			need = "memory request"
+		case corev1.ResourceLimitsCPU:
+			quantity, ok = limits[corev1.ResourceCPU]
+			need = "cpu limit"
+		case corev1.ResourceLimitsMemory:
+			quantity, ok = limits[corev1.ResourceMemory]
+			need = "memory limit"
+		case "count/deployments.apps", corev1.ResourceServices, "count/services":
+			if !lo.Contains(creates, name) {
+				continue
+			}
+			if remaining := remainingQuota(quota, name); remaining.Sign() <= 0 {
+				problems = append(problems, fmt.Sprintf("resource quota %s: no %s left", quota.Name, name))
+			}
+			continue
+		default:
+			continue
+		}
+		if !ok {
+			problems = append(problems, fmt.Sprintf("resource quota %s: pods need a %s", quota.Name, need))
+			continue
+		}
+		if !quantity.IsZero() {
+			perPod[name] = quantity
+		}
+	}
+	if len(perPod) == 0 {
+		return nil, problems
+	}
+	remaining := lo.MapValues(perPod, func(_ resource.Quantity, name corev1.ResourceName) resource.Quantity {
+		return remainingQuota(quota, name)
+	})
+	replicas, limit := fitInto(remaining, perPod)
+	return &Fit{Name: quota.Name, Replicas: replicas, Limit: limit}, problems
+}
+
+func remainingQuota(quota corev1.ResourceQuota, name corev1.ResourceName) resource.Quantity {
+	remaining := quota.Spec.Hard[name].DeepCopy()
+	remaining.Sub(quota.Status.Used[name])
+	return remaining
+}
+
+// fitInto returns how many times the requests fit into the free resources and the resource that runs out first
+func fitInto(free, requests corev1.ResourceList) (int64, string) {
+	replicas, limit := int64(-1), ""
+	for name, request := range requests {
+		if request.IsZero() {
+			continue
+		}
+		available := free[name]
+		fits := lo.Max([]int64{0, available.MilliValue() / request.MilliValue()})
+		if replicas == -1 || fits < replicas || (fits == replicas && string(name) < limit) {
+			replicas, limit = fits, string(name)
+		}
+	}
+	return lo.Max([]int64{0, replicas}), limit
+}
+
+// spreadReplicas bounds the replicas the nodes can run by the DoNotSchedule topology spread constraints.
+// With a max skew of s, no domain can run more than s replicas above the domain that fits the fewest.
+func spreadReplicas(constraints []corev1.TopologySpreadConstraint, nodes []corev1.Node, fits map[string]int64) int64 {
+	total := lo.SumBy(nodes, func(node corev1.Node) int64 { return fits[node.Name] })
+	for _, constraint := range constraints {
+		if constraint.WhenUnsatisfiable != corev1.DoNotSchedule {
+			continue
+		}
+		domains := map[string]int64{}
+		for _, node := range nodes {
+			if domain, ok := node.Labels[constraint.TopologyKey]; ok {
+				domains[domain] += fits[node.Name]
+			}
+		}
+		if len(domains) == 0 {
+			return 0
+		}
+		fewest := lo.Min(lo.Values(domains))
+		spread := lo.SumBy(lo.Values(domains), func(fit int64) int64 {
+			return lo.Min([]int64{fit, fewest + int64(constraint.MaxSkew)})
+		})
+		total = lo.Min([]int64{total, spread})
+	}
+	return total
+}
+
+// addResources adds factor times b to a copy of a
+func addResources(a, b corev1.ResourceList, factor int64) corev1.ResourceList {
+	sum := corev1.ResourceList{}
+	for name, quantity := range a {
+		sum[name] = quantity.DeepCopy()
+	}
+	for name, quantity := range b {
+		current := sum[name]
+		current.Add(multiply(quantity, factor))
+		sum[name] = current
+	}
+	return sum
+}
+
+// subtractResources returns what is left of a after b for the resources of a
+func subtractResources(a, b corev1.ResourceList) corev1.ResourceList {
+	difference := corev1.ResourceList{}
+	for name, quantity := range a {
+		left := quantity.DeepCopy()
+		left.Sub(b[name])
+		difference[name] = left
+	}
+	return difference
+}
+
+func multiply(quantity resource.Quantity, factor int64) resource.Quantity {
+	return *resource.NewMilliQuantity(quantity.MilliValue()*factor, quantity.Format)
+}
@@ -0,0 +1,213 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestQuotaFit(t *testing.T) {
+	requests := corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")}
+	limits := corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("2Gi")}
+	quota := func(hard, used corev1.ResourceList) corev1.ResourceQuota {
+		return corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "quota"},
+			Spec:       corev1.ResourceQuotaSpec{Hard: hard},
+			Status:     corev1.ResourceQuotaStatus{Used: used},
+		}
+	}
+	for _, tc := range []struct {
+		name         string
+		quota        corev1.ResourceQuota
+		creates      []corev1.ResourceName
+		want         *Fit
+		wantProblems []string
+	}{
+		{
+			name: "cpu runs out first",
+			quota: quota(
+				corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("4"), corev1.ResourcePods: resource.MustParse("20")},
+				corev1.ResourceList{corev1.ResourceRequestsCPU: resource.MustParse("1"), corev1.ResourcePods: resource.MustParse("2")},
+			),
+			want: &Fit{Name: "quota", Replicas: 6, Limit: "requests.cpu"},
+		},
+		{
+			name: "pods run out first",
+			quota: quota(
+				corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100"), "count/pods": resource.MustParse("5")},
+				corev1.ResourceList{"count/pods": resource.MustParse("2")},
+			),
+			want: &Fit{Name: "quota", Replicas: 3, Limit: "count/pods"},
+		},
+		{
+			name: "exhausted quota",
+			quota: quota(
+				corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("4Gi")},
+				corev1.ResourceList{corev1.ResourceLimitsMemory: resource.MustParse("5Gi")},
+			),
+			want: &Fit{Name: "quota", Replicas: 0, Limit: "limits.memory"},
+		},
+		{
+			name:         "missing limit",
+			quota:        quota(corev1.ResourceList{corev1.ResourceLimitsCPU: resource.MustParse("4"), corev1.ResourcePods: resource.MustParse("10")}, nil),
+			want:         &Fit{Name: "quota", Replicas: 10, Limit: "pods"},
+			wantProblems: []string{"resource quota quota: pods need a cpu limit"},
+		},
+		{
+			name:  "quota that limits no pod resources is unbounded",
+			quota: quota(corev1.ResourceList{"count/configmaps": resource.MustParse("1")}, corev1.ResourceList{"count/configmaps": resource.MustParse("1")}),
+		},
+		{
+			name: "full deployment count of a new inflate",
+			quota: quota(
+				corev1.ResourceList{"count/deployments.apps": resource.MustParse("2")},
+				corev1.ResourceList{"count/deployments.apps": resource.MustParse("2")},
+			),
+			creates:      []corev1.ResourceName{"count/deployments.apps"},
+			wantProblems: []string{"resource quota quota: no count/deployments.apps left"},
+		},
+		{
+			name: "full deployment count of an existing inflate",
+			quota: quota(
+				corev1.ResourceList{"count/deployments.apps": resource.MustParse("2")},
+				corev1.ResourceList{"count/deployments.apps": resource.MustParse("2")},
+			),
+		},
+		{
+			name: "full service count",
+			quota: quota(
+				corev1.ResourceList{corev1.ResourceServices: resource.MustParse("1"), corev1.ResourcePods: resource.MustParse("4")},
+				corev1.ResourceList{corev1.ResourceServices: resource.MustParse("1")},
+			),
+			creates:      []corev1.ResourceName{"count/deployments.apps", corev1.ResourceServices, "count/services"},
+			want:         &Fit{Name: "quota", Replicas: 4, Limit: "pods"},
+			wantProblems: []string{"resource quota quota: no services left"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, problems := quotaFit(tc.quota, requests, limits, tc.creates)
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("quotaFit() = %+v, want %+v", got, tc.want)
+			}
+			if !reflect.DeepEqual(problems, tc.wantProblems) {
+				t.Errorf("quotaFit() problems = %q, want %q", problems, tc.wantProblems)
+			}
+		})
+	}
+}
+
+func TestFitInto(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		free         corev1.ResourceList
+		requests     corev1.ResourceList
+		wantReplicas int64
+		wantLimit    string
+	}{
+		{
+			name:         "fewest fits",
+			free:         corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("4"), corev1.ResourceMemory: resource.MustParse("4Gi")},
+			requests:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			wantReplicas: 4,
+			wantLimit:    "memory",
+		},
+		{
+			name:         "ties go to the first name",
+			free:         corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("2"), corev1.ResourcePods: resource.MustParse("2")},
+			requests:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourcePods: resource.MustParse("1")},
+			wantReplicas: 2,
+			wantLimit:    "cpu",
+		},
+		{
+			name:         "missing and negative free resources fit none",
+			free:         corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("-1")},
+			requests:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("1"), corev1.ResourceMemory: resource.MustParse("1Gi")},
+			wantReplicas: 0,
+			wantLimit:    "cpu",
+		},
+		{
+			name:         "zero requests are skipped",
+			free:         corev1.ResourceList{corev1.ResourcePods: resource.MustParse("3")},
+			requests:     corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("0"), corev1.ResourcePods: resource.MustParse("1")},
+			wantReplicas: 3,
+			wantLimit:    "pods",
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			replicas, limit := fitInto(tc.free, tc.requests)
+			if replicas != tc.wantReplicas || limit != tc.wantLimit {
+				t.Errorf("fitInto() = %d, %q, want %d, %q", replicas, limit, tc.wantReplicas, tc.wantLimit)
+			}
+		})
+	}
+}
+
+func TestSpreadReplicas(t *testing.T) {
+	node := func(name, zone string) corev1.Node {
+		labels := map[string]string{corev1.LabelHostname: name}
+		if zone != "" {
+			labels[corev1.LabelTopologyZone] = zone
+		}
+		return corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+	}
+	zonal := func(maxSkew int32, whenUnsatisfiable corev1.UnsatisfiableConstraintAction) corev1.TopologySpreadConstraint {
+		return corev1.TopologySpreadConstraint{MaxSkew: maxSkew, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: whenUnsatisfiable}
+	}
+	nodes := []corev1.Node{node("a1", "a"), node("a2", "a"), node("b1", "b")}
+	fits := map[string]int64{"a1": 4, "a2": 6, "b1": 2}
+	for _, tc := range []struct {
+		name        string
+		constraints []corev1.TopologySpreadConstraint
+		nodes       []corev1.Node
+		want        int64
+	}{
+		{name: "no constraints", nodes: nodes, want: 12},
+		{name: "max skew bounds the larger domain", constraints: []corev1.TopologySpreadConstraint{zonal(1, corev1.DoNotSchedule)}, nodes: nodes, want: 5},
+		{name: "large max skew", constraints: []corev1.TopologySpreadConstraint{zonal(10, corev1.DoNotSchedule)}, nodes: nodes, want: 12},
+		{name: "ScheduleAnyway is ignored", constraints: []corev1.TopologySpreadConstraint{zonal(1, corev1.ScheduleAnyway)}, nodes: nodes, want: 12},
+		{
+			name:        "nodes without the topology key are not counted",
+			constraints: []corev1.TopologySpreadConstraint{zonal(1, corev1.DoNotSchedule)},
+			nodes:       append([]corev1.Node{node("c1", "")}, nodes...),
+			want:        5,
+		},
+		{
+			name:        "no domains",
+			constraints: []corev1.TopologySpreadConstraint{zonal(1, corev1.DoNotSchedule)},
+			nodes:       []corev1.Node{node("c1", "")},
+			want:        0,
+		},
+		{
+			name: "the tightest constraint wins",
+			constraints: []corev1.TopologySpreadConstraint{
+				zonal(10, corev1.DoNotSchedule),
+				{MaxSkew: 1, TopologyKey: corev1.LabelHostname, WhenUnsatisfiable: corev1.DoNotSchedule},
+			},
+			nodes: nodes,
+			want:  8,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := spreadReplicas(tc.constraints, tc.nodes, fits); got != tc.want {
+				t.Errorf("spreadReplicas() = %d, want %d", got, tc.want)
+			}
+		})
+	}
+}
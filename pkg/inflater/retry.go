@@ -0,0 +1,63 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-logr/logr"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// RetryBackoff is used to retry requests that were throttled or failed on the server
+var RetryBackoff = wait.Backoff{
+	Steps:    5,
+	Duration: 500 * time.Millisecond,
+	Factor:   2,
+	Jitter:   0.1,
+	Cap:      10 * time.Second,
+}
+
+// withRetry calls fn until it succeeds, fails with an error that is not retryable, or RetryBackoff is exhausted.
+// Retry decisions are logged at verbosity 1.
+func withRetry(logger logr.Logger, fn func() error) error {
+	attempt := 0
+	return retry.OnError(RetryBackoff, func(err error) bool {
+		attempt++
+		if !retryable(err) {
+			return false
+		}
+		if attempt >= RetryBackoff.Steps {
+			logger.V(1).Info("giving up on request", "attempts", attempt, "error", err.Error())
+			return false
+		}
+		logger.V(1).Info("retrying request", "attempt", attempt, "error", err.Error())
+		return true
+	}, fn)
+}
+
+// retryable is true for 429 Too Many Requests and 5xx responses
+func retryable(err error) bool {
+	var status apierrors.APIStatus
+	if !errors.As(err, &status) {
+		return false
+	}
+	code := status.Status().Code
+	return code == http.StatusTooManyRequests || code >= http.StatusInternalServerError
+}
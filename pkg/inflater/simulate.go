@@ -0,0 +1,351 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// defaultAllocatablePods is the allocatable pods of an inventory node that does not set it, the kubelet's default max pods
+const defaultAllocatablePods = 110
+
+// InventoryNode is a node of the inventory to simulate against, Count copies it as <name>-1 to <name>-<count>
+type InventoryNode struct {
+	Name          string              `json:"name"`
+	Count         int                 `json:"count,omitempty"`
+	Labels        map[string]string   `json:"labels,omitempty"`
+	Taints        []corev1.Taint      `json:"taints,omitempty"`
+	Unschedulable bool                `json:"unschedulable,omitempty"`
+	Allocatable   corev1.ResourceList `json:"allocatable"`
+}
+
+// SimulateOptions are the inflates to place, in order, on the nodes
+type SimulateOptions struct {
+	Inflates []PlanOptions
+	Nodes    []corev1.Node
+}
+
+// Placement is the number of replicas of an inflate placed on a node
+type Placement struct {
+	Node     string
+	Replicas int32
+}
+
+// InflateSimulation is where the replicas of an inflate were placed
+type InflateSimulation struct {
+	Namespace     string
+	Name          string
+	Replicas      int32
+	Scheduled     int32
+	Unschedulable int32
+	Placements    []Placement
+	// Reasons are why the first unschedulable replica did not fit on the nodes, like a FailedScheduling event
+	Reasons      []SchedulingReason
+	LikelyCauses []string
+}
+
+// NodeSimulation is what the placed replicas request of a node
+type NodeSimulation struct {
+	Name        string
+	Pods        int
+	Requested   corev1.ResourceList
+	Allocatable corev1.ResourceList
+}
+
+// Simulation is the outcome of placing the replicas of the inflates without a cluster
+type Simulation struct {
+	Inflates   []InflateSimulation
+	Nodes      []NodeSimulation
+	TotalNodes int
+}
+
+// simulatedPod is a placed replica, what the filters of later replicas need to know about it
+type simulatedPod struct {
+	namespace string
+	labels    map[string]string
+	affinity  *corev1.Affinity
+	node      *corev1.Node
+}
+
+// scheduler is a simplified kube-scheduler that places one pod at a time. It filters the nodes by
+// node selector, taints, resources, required pod anti-affinity and DoNotSchedule topology spread, then prefers
+// the node with the fewest matching pods in its topology domains and after that the least allocated one.
+type scheduler struct {
+	nodes     []corev1.Node
+	requested map[string]corev1.ResourceList
+	pods      []simulatedPod
+}
+
+// ParseNodeInventory parses a yaml list of InventoryNode, or a NodeList like the one kubectl get nodes -o yaml prints
+func ParseNodeInventory(data []byte) ([]corev1.Node, error) {
+	var nodeList corev1.NodeList
+	if err := yaml.Unmarshal(data, &nodeList); err == nil && len(nodeList.Items) > 0 {
+		return nodeList.Items, nil
+	}
+	var inventory []InventoryNode
+	if err := yaml.Unmarshal(data, &inventory); err != nil {
+		return nil, err
+	}
+	var nodes []corev1.Node
+	for idx, inventoryNode := range inventory {
+		if inventoryNode.Name == "" {
+			return nil, NewValidationError("node %d has no name", idx)
+		}
+		if len(inventoryNode.Allocatable) == 0 {
+			return nil, NewValidationError("node %s has no allocatable resources", inventoryNode.Name)
+		}
+		names := []string{inventoryNode.Name}
+		if inventoryNode.Count > 1 {
+			names = lo.Map(lo.RangeFrom(1, inventoryNode.Count), func(n int, _ int) string { return fmt.Sprintf("%s-%d", inventoryNode.Name, n) })
+		}
+		for _, name := range names {
+			node := corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelHostname: name}},
+				Spec:       corev1.NodeSpec{Taints: inventoryNode.Taints, Unschedulable: inventoryNode.Unschedulable},
+				Status:     corev1.NodeStatus{Allocatable: addResources(inventoryNode.Allocatable, nil, 1)},
+			}
+			for key, value := range inventoryNode.Labels {
+				node.Labels[key] = value
+			}
+			if _, ok := node.Status.Allocatable[corev1.ResourcePods]; !ok {
+				node.Status.Allocatable[corev1.ResourcePods] = *resource.NewQuantity(defaultAllocatablePods, resource.DecimalSI)
+			}
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes, nil
+}
+
+// Simulate places the replicas of the inflates on the nodes one at a time, in the order of the inflates,
+// and reports the placements and the replicas that could not be scheduled. No cluster is needed.
+func (i Inflater) Simulate(ctx context.Context, opts SimulateOptions) (*Simulation, error) {
+	s := &scheduler{nodes: opts.Nodes, requested: map[string]corev1.ResourceList{}}
+	simulation := &Simulation{TotalNodes: len(opts.Nodes)}
+	for _, inflate := range opts.Inflates {
+		deployment, err := i.GetInflateDeployment(ctx, inflate.Options)
+		if err != nil {
+			return nil, err
+		}
+		result := InflateSimulation{Namespace: deployment.Namespace, Name: deployment.Name, Replicas: inflate.Replicas}
+		placements := map[string]int32{}
+		for replica := int32(0); replica < inflate.Replicas; replica++ {
+			node, reasons := s.schedule(deployment.Namespace, deployment.Spec.Template)
+			if node == nil {
+				if result.Unschedulable == 0 {
+					result.Reasons = reasons
+					result.LikelyCauses = likelyCauses(*deployment, reasons)
+				}
+				result.Unschedulable++
+				continue
+			}
+			result.Scheduled++
+			placements[node.Name]++
+		}
+		result.Placements = lo.FilterMap(opts.Nodes, func(node corev1.Node, _ int) (Placement, bool) {
+			return Placement{Node: node.Name, Replicas: placements[node.Name]}, placements[node.Name] > 0
+		})
+		simulation.Inflates = append(simulation.Inflates, result)
+	}
+	simulation.Nodes = lo.Map(opts.Nodes, func(node corev1.Node, _ int) NodeSimulation {
+		return NodeSimulation{
+			Name:        node.Name,
+			Pods:        lo.CountBy(s.pods, func(pod simulatedPod) bool { return pod.node.Name == node.Name }),
+			Requested:   s.requested[node.Name],
+			Allocatable: node.Status.Allocatable,
+		}
+	})
+	return simulation, nil
+}
+
+// schedule places a pod on the best feasible node, or returns the number of nodes rejected for each reason
+func (s *scheduler) schedule(namespace string, template corev1.PodTemplateSpec) (*corev1.Node, []SchedulingReason) {
+	// the domain counts of each topology spread constraint only change when a pod is placed
+	spreadCounts := lo.Map(template.Spec.TopologySpreadConstraints, func(constraint corev1.TopologySpreadConstraint, _ int) map[string]int {
+		return s.domainCounts(namespace, template.Spec, constraint)
+	})
+	rejected := map[string]int{}
+	var feasible []*corev1.Node
+	spread, allocated := map[string]int{}, map[string]float64{}
+	for idx := range s.nodes {
+		node := &s.nodes[idx]
+		if reason := s.filter(namespace, template, spreadCounts, node); reason != "" {
+			rejected[reason]++
+			continue
+		}
+		feasible = append(feasible, node)
+		spread[node.Name] = spreadScore(template.Spec.TopologySpreadConstraints, spreadCounts, node)
+		allocated[node.Name] = s.allocated(node)
+	}
+	if len(feasible) == 0 {
+		reasons := lo.MapToSlice(rejected, func(category string, nodes int) SchedulingReason {
+			return SchedulingReason{Category: category, Nodes: nodes}
+		})
+		sort.Slice(reasons, func(a, b int) bool {
+			return reasons[a].Nodes > reasons[b].Nodes || (reasons[a].Nodes == reasons[b].Nodes && reasons[a].Category < reasons[b].Category)
+		})
+		return nil, reasons
+	}
+	sort.SliceStable(feasible, func(a, b int) bool {
+		if spreadA, spreadB := spread[feasible[a].Name], spread[feasible[b].Name]; spreadA != spreadB {
+			return spreadA < spreadB
+		}
+		if allocatedA, allocatedB := allocated[feasible[a].Name], allocated[feasible[b].Name]; allocatedA != allocatedB {
+			return allocatedA < allocatedB
+		}
+		return feasible[a].Name < feasible[b].Name
+	})
+	node := feasible[0]
+	s.requested[node.Name] = addResources(s.requested[node.Name], podResources(template.Spec), 1)
+	s.pods = append(s.pods, simulatedPod{namespace: namespace, labels: template.Labels, affinity: template.Spec.Affinity, node: node})
+	return node, nil
+}
+
+// filter returns why the pod cannot run on the node, or an empty string when it can.
+// spreadCounts are the domain counts of the pod's topology spread constraints.
+func (s *scheduler) filter(namespace string, template corev1.PodTemplateSpec, spreadCounts []map[string]int, node *corev1.Node) string {
+	switch {
+	case node.Spec.Unschedulable:
+		return ReasonUnschedulableNode
+	case !matchesNodeSelector(template.Spec, *node):
+		return ReasonNodeSelector
+	case !toleratesTaints(template.Spec, *node):
+		return ReasonTaint
+	}
+	free := subtractResources(node.Status.Allocatable, s.requested[node.Name])
+	requests := podResources(template.Spec)
+	names := lo.Keys(requests)
+	sort.Slice(names, func(a, b int) bool { return names[a] < names[b] })
+	for _, name := range names {
+		request, available := requests[name], free[name]
+		if request.Cmp(available) <= 0 {
+			continue
+		}
+		switch name {
+		case corev1.ResourceCPU:
+			return ReasonInsufficientCPU
+		case corev1.ResourceMemory:
+			return ReasonInsufficientMemory
+		case corev1.ResourcePods:
+			return ReasonTooManyPods
+		default:
+			return fmt.Sprintf("insufficient %s", name)
+		}
+	}
+	if s.violatesAntiAffinity(namespace, template, node) {
+		return ReasonPodAntiAffinity
+	}
+	for idx, constraint := range template.Spec.TopologySpreadConstraints {
+		if constraint.WhenUnsatisfiable != corev1.DoNotSchedule {
+			continue
+		}
+		domain, ok := node.Labels[constraint.TopologyKey]
+		if !ok {
+			return ReasonTopologySpread
+		}
+		counts := spreadCounts[idx]
+		selfMatch := lo.Ternary(selectorMatches(constraint.LabelSelector, template.Labels), 1, 0)
+		if counts[domain]+selfMatch-lo.Min(lo.Values(counts)) > int(constraint.MaxSkew) {
+			return ReasonTopologySpread
+		}
+	}
+	return ""
+}
+
+// violatesAntiAffinity is true when a placed pod in the same domain matches a required anti-affinity term of the pod, or the other way around
+func (s *scheduler) violatesAntiAffinity(namespace string, template corev1.PodTemplateSpec, node *corev1.Node) bool {
+	return lo.ContainsBy(s.pods, func(pod simulatedPod) bool {
+		return lo.ContainsBy(antiAffinityTerms(template.Spec.Affinity), func(term corev1.PodAffinityTerm) bool {
+			return termMatches(term, namespace, pod.namespace, pod.labels) && sameDomain(term.TopologyKey, node, pod.node)
+		}) || lo.ContainsBy(antiAffinityTerms(pod.affinity), func(term corev1.PodAffinityTerm) bool {
+			return termMatches(term, pod.namespace, namespace, template.Labels) && sameDomain(term.TopologyKey, node, pod.node)
+		})
+	})
+}
+
+// domainCounts counts the placed pods matching a topology spread constraint in each domain of the nodes matching the pod's node selector
+func (s *scheduler) domainCounts(namespace string, podSpec corev1.PodSpec, constraint corev1.TopologySpreadConstraint) map[string]int {
+	counts := map[string]int{}
+	for _, node := range s.nodes {
+		if domain, ok := node.Labels[constraint.TopologyKey]; ok && matchesNodeSelector(podSpec, node) {
+			if _, seen := counts[domain]; !seen {
+				counts[domain] = 0
+			}
+		}
+	}
+	for _, pod := range s.pods {
+		domain, ok := pod.node.Labels[constraint.TopologyKey]
+		if _, eligible := counts[domain]; ok && eligible && pod.namespace == namespace && selectorMatches(constraint.LabelSelector, pod.labels) {
+			counts[domain]++
+		}
+	}
+	return counts
+}
+
+// spreadScore is the number of matching pods in the domains of the node for all topology spread constraints, lower is better
+func spreadScore(constraints []corev1.TopologySpreadConstraint, spreadCounts []map[string]int, node *corev1.Node) int {
+	score := 0
+	for idx, constraint := range constraints {
+		score += spreadCounts[idx][node.Labels[constraint.TopologyKey]]
+	}
+	return score
+}
+
+// allocated is the mean fraction of the node's allocatable cpu and memory that is requested
+func (s *scheduler) allocated(node *corev1.Node) float64 {
+	fraction := func(name corev1.ResourceName) float64 {
+		allocatable, requested := node.Status.Allocatable[name], s.requested[node.Name][name]
+		if allocatable.IsZero() {
+			return 1
+		}
+		return float64(requested.MilliValue()) / float64(allocatable.MilliValue())
+	}
+	return (fraction(corev1.ResourceCPU) + fraction(corev1.ResourceMemory)) / 2
+}
+
+func antiAffinityTerms(affinity *corev1.Affinity) []corev1.PodAffinityTerm {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return nil
+	}
+	return affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+}
+
+// termMatches is true when a term of a pod in termNamespace selects a pod in namespace with the labels
+func termMatches(term corev1.PodAffinityTerm, termNamespace string, namespace string, podLabels map[string]string) bool {
+	namespaces := lo.Ternary(len(term.Namespaces) == 0, []string{termNamespace}, term.Namespaces)
+	return lo.Contains(namespaces, namespace) && selectorMatches(term.LabelSelector, podLabels)
+}
+
+func sameDomain(topologyKey string, a, b *corev1.Node) bool {
+	domainA, okA := a.Labels[topologyKey]
+	domainB, okB := b.Labels[topologyKey]
+	return okA && okB && domainA == domainB
+}
+
+func selectorMatches(labelSelector *metav1.LabelSelector, podLabels map[string]string) bool {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		return false
+	}
+	return selector.Matches(labels.Set(podLabels))
+}
@@ -0,0 +1,332 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseNodeInventory(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		data      string
+		wantNames []string
+		// wantHostnames is true when every node has its name as hostname label
+		wantHostnames bool
+		// wantPods is the allocatable pods of every node
+		wantPods       int64
+		wantTaints     int
+		wantValidation bool
+		wantErr        bool
+	}{
+		{
+			name: "inventory with counts",
+			data: `
+- name: spot
+  count: 2
+  labels:
+    topology.kubernetes.io/zone: us-west-2a
+  taints:
+  - key: dedicated
+    value: batch
+    effect: NoSchedule
+  allocatable:
+    cpu: "4"
+    memory: 16Gi
+- name: single
+  count: 1
+  taints:
+  - key: dedicated
+    value: batch
+    effect: NoSchedule
+  allocatable:
+    cpu: "2"
+    pods: "110"
+`,
+			wantNames:     []string{"spot-1", "spot-2", "single"},
+			wantHostnames: true,
+			wantPods:      defaultAllocatablePods,
+			wantTaints:    1,
+		},
+		{
+			name: "node list",
+			data: `
+apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: real-1
+  status:
+    allocatable:
+      cpu: "2"
+      pods: "58"
+`,
+			wantNames: []string{"real-1"},
+			wantPods:  58,
+		},
+		{name: "missing name", data: "- allocatable:\n    cpu: \"1\"\n", wantErr: true, wantValidation: true},
+		{name: "missing allocatable", data: "- name: x\n", wantErr: true, wantValidation: true},
+		{name: "invalid yaml", data: "name: x\n", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes, err := ParseNodeInventory([]byte(tc.data))
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseNodeInventory() error = %v, want error %t", err, tc.wantErr)
+			}
+			var validationError *ValidationError
+			if errors.As(err, &validationError) != tc.wantValidation {
+				t.Errorf("ParseNodeInventory() error = %v, want a validation error %t", err, tc.wantValidation)
+			}
+			if err != nil {
+				return
+			}
+			var names []string
+			for _, node := range nodes {
+				names = append(names, node.Name)
+				if tc.wantHostnames && node.Labels[corev1.LabelHostname] != node.Name {
+					t.Errorf("node %s has hostname label %q", node.Name, node.Labels[corev1.LabelHostname])
+				}
+				if pods := node.Status.Allocatable[corev1.ResourcePods]; pods.Value() != tc.wantPods {
+					t.Errorf("node %s allocatable pods = %s, want %d", node.Name, pods.String(), tc.wantPods)
+				}
+				if len(node.Spec.Taints) != tc.wantTaints {
+					t.Errorf("node %s taints = %v, want %d", node.Name, node.Spec.Taints, tc.wantTaints)
+				}
+			}
+			if !reflect.DeepEqual(names, tc.wantNames) {
+				t.Errorf("ParseNodeInventory() names = %v, want %v", names, tc.wantNames)
+			}
+		})
+	}
+}
+
+// simulationNode is a node with the hostname and zone labels and the cpu, 16Gi of memory and 110 pods allocatable
+func simulationNode(name, zone, cpu string) corev1.Node {
+	return corev1.Node{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{corev1.LabelHostname: name, corev1.LabelTopologyZone: zone}},
+		Status: corev1.NodeStatus{Allocatable: corev1.ResourceList{
+			corev1.ResourceCPU:    resource.MustParse(cpu),
+			corev1.ResourceMemory: resource.MustParse("16Gi"),
+			corev1.ResourcePods:   resource.MustParse("110"),
+		}},
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	tainted := simulationNode("gpu", "a", "16")
+	tainted.Spec.Taints = []corev1.Taint{{Key: "gpu", Value: "true", Effect: corev1.TaintEffectNoSchedule}}
+	cordoned := simulationNode("cordoned", "b", "16")
+	cordoned.Spec.Unschedulable = true
+	inflate := func(name string, replicas int32, zonalSpread bool) PlanOptions {
+		return PlanOptions{Options: Options{Name: name, Namespace: "inflate", Image: DefaultImage, ZonalSpread: zonalSpread}, Replicas: replicas}
+	}
+	for _, tc := range []struct {
+		name     string
+		inflates []PlanOptions
+		nodes    []corev1.Node
+		want     []InflateSimulation
+	}{
+		{
+			name:     "least allocated node first",
+			inflates: []PlanOptions{inflate("web", 3, false)},
+			nodes:    []corev1.Node{simulationNode("x", "a", "4"), simulationNode("y", "a", "2")},
+			want: []InflateSimulation{{
+				Namespace: "inflate", Name: "web", Replicas: 3, Scheduled: 3,
+				Placements: []Placement{{Node: "x", Replicas: 2}, {Node: "y", Replicas: 1}},
+			}},
+		},
+		{
+			name:     "zonal spread",
+			inflates: []PlanOptions{inflate("web", 4, true)},
+			nodes:    []corev1.Node{simulationNode("a-1", "a", "8"), simulationNode("a-2", "a", "8"), simulationNode("b-1", "b", "2")},
+			want: []InflateSimulation{{
+				Namespace: "inflate", Name: "web", Replicas: 4, Scheduled: 4,
+				Placements: []Placement{{Node: "a-1", Replicas: 1}, {Node: "a-2", Replicas: 1}, {Node: "b-1", Replicas: 2}},
+			}},
+		},
+		{
+			name:     "zonal spread blocked by a full zone",
+			inflates: []PlanOptions{inflate("web", 4, true)},
+			nodes:    []corev1.Node{simulationNode("a-1", "a", "8"), simulationNode("b-1", "b", "1")},
+			want: []InflateSimulation{{
+				Namespace: "inflate", Name: "web", Replicas: 4, Scheduled: 3, Unschedulable: 1,
+				Placements: []Placement{{Node: "a-1", Replicas: 2}, {Node: "b-1", Replicas: 1}},
+				Reasons:    []SchedulingReason{{Category: ReasonInsufficientCPU, Nodes: 1}, {Category: ReasonTopologySpread, Nodes: 1}},
+				LikelyCauses: []string{
+					"insufficient cpu: each pod requests cpu=1 memory=256",
+					"topology spread: DoNotSchedule spread on topology.kubernetes.io/zone with maxSkew 1 (--zonal-spread)",
+				},
+			}},
+		},
+		{
+			name: "a toleration admits the tainted node",
+			inflates: []PlanOptions{{Options: Options{Name: "gpu", Namespace: "inflate", Image: DefaultImage,
+				Tolerations: []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpEqual, Value: "true", Effect: corev1.TaintEffectNoSchedule}},
+			}, Replicas: 2}},
+			nodes: []corev1.Node{tainted, cordoned},
+			want: []InflateSimulation{{
+				Namespace: "inflate", Name: "gpu", Replicas: 2, Scheduled: 2,
+				Placements: []Placement{{Node: "gpu", Replicas: 2}},
+			}},
+		},
+		{
+			name: "hostname anti-affinity leaves replicas unschedulable",
+			inflates: []PlanOptions{{Options: Options{Name: "web", Namespace: "inflate", Image: DefaultImage,
+				AntiAffinity: corev1.LabelHostname,
+			}, Replicas: 3}},
+			nodes: []corev1.Node{simulationNode("x", "a", "4"), simulationNode("y", "b", "4")},
+			want: []InflateSimulation{{
+				Namespace: "inflate", Name: "web", Replicas: 3, Scheduled: 2, Unschedulable: 1,
+				Placements:   []Placement{{Node: "x", Replicas: 1}, {Node: "y", Replicas: 1}},
+				Reasons:      []SchedulingReason{{Category: ReasonPodAntiAffinity, Nodes: 2}},
+				LikelyCauses: []string{"pod anti-affinity: required pod anti-affinity allows one matching pod per kubernetes.io/hostname (--anti-affinity)"},
+			}},
+		},
+		{
+			name:     "later inflates see the replicas of earlier ones",
+			inflates: []PlanOptions{inflate("web", 2, false), inflate("cache", 2, false)},
+			nodes:    []corev1.Node{simulationNode("x", "a", "3"), tainted, cordoned},
+			want: []InflateSimulation{
+				{Namespace: "inflate", Name: "web", Replicas: 2, Scheduled: 2, Placements: []Placement{{Node: "x", Replicas: 2}}},
+				{
+					Namespace: "inflate", Name: "cache", Replicas: 2, Scheduled: 1, Unschedulable: 1,
+					Placements: []Placement{{Node: "x", Replicas: 1}},
+					Reasons: []SchedulingReason{
+						{Category: ReasonInsufficientCPU, Nodes: 1},
+						{Category: ReasonUnschedulableNode, Nodes: 1},
+						{Category: ReasonTaint, Nodes: 1},
+					},
+					LikelyCauses: []string{
+						"insufficient cpu: each pod requests cpu=1 memory=256",
+						"taint mismatch: pods have no tolerations for the tainted nodes",
+					},
+				},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			simulation, err := New(nil).Simulate(context.Background(), SimulateOptions{Inflates: tc.inflates, Nodes: tc.nodes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(simulation.Inflates, tc.want) {
+				t.Errorf("Simulate() inflates = %+v, want %+v", simulation.Inflates, tc.want)
+			}
+			if simulation.TotalNodes != len(tc.nodes) {
+				t.Errorf("Simulate() total nodes = %d, want %d", simulation.TotalNodes, len(tc.nodes))
+			}
+		})
+	}
+}
+
+func TestScheduleAntiAffinity(t *testing.T) {
+	// antiAffinity is a pod template with the labels and a required anti-affinity on the topology key for pods with the app label
+	antiAffinity := func(labels map[string]string, topologyKey string, app string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{
+			ObjectMeta: metav1.ObjectMeta{Labels: labels},
+			Spec: corev1.PodSpec{Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{
+					LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": app}},
+					TopologyKey:   topologyKey,
+				}},
+			}}},
+		}
+	}
+	plain := func(labels map[string]string) corev1.PodTemplateSpec {
+		return corev1.PodTemplateSpec{ObjectMeta: metav1.ObjectMeta{Labels: labels}}
+	}
+	type pod struct {
+		namespace string
+		template  corev1.PodTemplateSpec
+	}
+	cache := map[string]string{"app": "cache"}
+	web := map[string]string{"app": "web"}
+	for _, tc := range []struct {
+		name string
+		pods []pod
+		// want is the node of each pod, empty when it is unschedulable
+		want        []string
+		wantReasons []SchedulingReason
+	}{
+		{
+			name: "one pod per zone",
+			pods: []pod{
+				{"inflate", antiAffinity(cache, corev1.LabelTopologyZone, "cache")},
+				{"inflate", antiAffinity(cache, corev1.LabelTopologyZone, "cache")},
+				{"inflate", antiAffinity(cache, corev1.LabelTopologyZone, "cache")},
+			},
+			want:        []string{"a-1", "b-1", ""},
+			wantReasons: []SchedulingReason{{Category: ReasonPodAntiAffinity, Nodes: 3}},
+		},
+		{
+			name: "one pod per hostname",
+			pods: []pod{
+				{"inflate", antiAffinity(cache, corev1.LabelHostname, "cache")},
+				{"inflate", antiAffinity(cache, corev1.LabelHostname, "cache")},
+				{"inflate", antiAffinity(cache, corev1.LabelHostname, "cache")},
+			},
+			want: []string{"a-1", "a-2", "b-1"},
+		},
+		{
+			name: "placed pods repel matching pods",
+			pods: []pod{
+				{"inflate", antiAffinity(cache, corev1.LabelTopologyZone, "web")},
+				{"inflate", plain(web)},
+			},
+			want: []string{"a-1", "b-1"},
+		},
+		{
+			name: "pods in other namespaces do not match",
+			pods: []pod{
+				{"inflate", antiAffinity(cache, corev1.LabelTopologyZone, "cache")},
+				{"other", antiAffinity(cache, corev1.LabelTopologyZone, "cache")},
+				{"other", plain(cache)},
+			},
+			want: []string{"a-1", "a-1", "b-1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := &scheduler{
+				nodes:     []corev1.Node{simulationNode("a-1", "a", "4"), simulationNode("a-2", "a", "4"), simulationNode("b-1", "b", "4")},
+				requested: map[string]corev1.ResourceList{},
+			}
+			var got []string
+			var reasons []SchedulingReason
+			for _, pod := range tc.pods {
+				node, podReasons := s.schedule(pod.namespace, pod.template)
+				if node == nil {
+					got, reasons = append(got, ""), podReasons
+					continue
+				}
+				got = append(got, node.Name)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("schedule() nodes = %v, want %v", got, tc.want)
+			}
+			if !reflect.DeepEqual(reasons, tc.wantReasons) {
+				t.Errorf("schedule() reasons = %+v, want %+v", reasons, tc.wantReasons)
+			}
+		})
+	}
+}
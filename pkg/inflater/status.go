@@ -0,0 +1,149 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StuckPendingThreshold is how long a pod may be pending before it is considered stuck
+const StuckPendingThreshold = time.Minute
+
+// InflateStatus summarizes the pods of a single inflate
+type InflateStatus struct {
+	Namespace    string
+	Name         string
+	Desired      int32
+	Ready        int32
+	Pending      int
+	StuckPending int
+	CrashLooping int
+	Nodes        []string
+	RecentEvent  string
+}
+
+// Unhealthy is true when pods are stuck pending or crash-looping
+func (s InflateStatus) Unhealthy() bool {
+	return s.StuckPending > 0 || s.CrashLooping > 0
+}
+
+// Status summarizes the inflates matching filters along with their pods and most recent event
+func (i Inflater) Status(ctx context.Context, filters ListFilters) ([]InflateStatus, error) {
+	deployments, errs := i.List(ctx, filters)
+	namespaces := lo.Uniq(lo.Map(deployments, func(deployment appsv1.Deployment, _ int) string { return deployment.Namespace }))
+	podsByNamespace := map[string][]corev1.Pod{}
+	eventsByNamespace := map[string][]corev1.Event{}
+	for _, ns := range namespaces {
+		podList, err := i.clientset.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+			LabelSelector: "managed-by=inflate",
+		})
+		if err != nil {
+			errs = multierr.Append(errs, err)
+		} else {
+			podsByNamespace[ns] = podList.Items
+		}
+		eventList, err := i.clientset.CoreV1().Events(ns).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			errs = multierr.Append(errs, err)
+		} else {
+			eventsByNamespace[ns] = eventList.Items
+		}
+	}
+	now := time.Now()
+	var statuses []InflateStatus
+	for _, deployment := range deployments {
+		status := InflateStatus{
+			Namespace: deployment.Namespace,
+			Name:      deployment.Name,
+			Desired:   lo.FromPtrOr(deployment.Spec.Replicas, 1),
+			Ready:     deployment.Status.ReadyReplicas,
+		}
+		podNames := map[string]struct{}{}
+		for _, pod := range podsByNamespace[deployment.Namespace] {
+			if pod.Labels["app"] != deployment.Name {
+				continue
+			}
+			podNames[pod.Name] = struct{}{}
+			if pod.Spec.NodeName != "" {
+				status.Nodes = append(status.Nodes, pod.Spec.NodeName)
+			}
+			if pod.Status.Phase == corev1.PodPending && pod.DeletionTimestamp == nil {
+				status.Pending++
+				if now.Sub(pod.CreationTimestamp.Time) > StuckPendingThreshold {
+					status.StuckPending++
+				}
+			}
+			if isCrashLooping(pod) {
+				status.CrashLooping++
+			}
+		}
+		status.Nodes = lo.Uniq(status.Nodes)
+		sort.Strings(status.Nodes)
+		status.RecentEvent = recentEvent(deployment, podNames, eventsByNamespace[deployment.Namespace])
+		statuses = append(statuses, status)
+	}
+	return statuses, errs
+}
+
+func isCrashLooping(pod corev1.Pod) bool {
+	return lo.SomeBy(append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...), func(status corev1.ContainerStatus) bool {
+		return status.State.Waiting != nil && status.State.Waiting.Reason == "CrashLoopBackOff"
+	})
+}
+
+// recentEvent returns the latest event about the deployment, its replica sets or its pods
+func recentEvent(deployment appsv1.Deployment, podNames map[string]struct{}, events []corev1.Event) string {
+	var latest *corev1.Event
+	for idx := range events {
+		event := &events[idx]
+		involved := event.InvolvedObject
+		_, isPod := podNames[involved.Name]
+		switch {
+		case involved.Kind == "Pod" && isPod:
+		case involved.Kind == "Deployment" && involved.Name == deployment.Name:
+		case involved.Kind == "ReplicaSet" && strings.HasPrefix(involved.Name, deployment.Name+"-"):
+		default:
+			continue
+		}
+		if latest == nil || eventTime(*event).After(eventTime(*latest)) {
+			latest = event
+		}
+	}
+	if latest == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s: %s", latest.Reason, latest.Message)
+}
+
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
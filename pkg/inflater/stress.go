@@ -0,0 +1,123 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// DefaultStressImage runs StressScript
+const DefaultStressImage = "public.ecr.aws/docker/library/python:3-alpine"
+
+// StressOptions configure a container that consumes CPU and memory
+type StressOptions struct {
+	// CPU is the number of busy loop processes
+	CPU int
+	// Memory is allocated and held, it is also the memory request
+	Memory *resource.Quantity
+	// MemoryLimit sets a container memory limit so that leaking ends in an OOMKill rather than node pressure eviction
+	MemoryLimit *resource.Quantity
+	// Duration stops the stress and idles afterwards, zero stresses forever
+	Duration time.Duration
+	// RampUp linearly increases the CPU processes and memory to their targets over this long
+	RampUp time.Duration
+	// Leak keeps allocating memory at the ramp-up rate after reaching the target, until the pod is killed
+	Leak bool
+}
+
+// StressScript is run with python3 -c, its arguments are generated by StressArgs
+const StressScript = `import argparse, math, multiprocessing, re, time
+from decimal import Decimal
+
+DECIMAL_SUFFIXES = {"m": -3, "": 0, "k": 3, "M": 6, "G": 9, "T": 12, "P": 15, "E": 18}
+BINARY_SUFFIXES = {"Ki": 10, "Mi": 20, "Gi": 30, "Ti": 40, "Pi": 50, "Ei": 60}
+
+# quantity parses a Kubernetes quantity into bytes, rounded up like resource.Quantity.Value
+def quantity(value):
+    match = re.fullmatch(r"([0-9.]+)(?:[eE]([+-]?[0-9]+))?(|m|k|M|G|T|P|E|Ki|Mi|Gi|Ti|Pi|Ei)", value)
+    if not match:
+        raise ValueError(value)
+    number, exponent, suffix = match.groups()
+    if suffix in BINARY_SUFFIXES:
+        scale = Decimal(2) ** BINARY_SUFFIXES[suffix]
+    else:
+        scale = Decimal(10) ** (DECIMAL_SUFFIXES[suffix] + int(exponent or 0))
+    return math.ceil(Decimal(number) * scale)
+
+# duration parses a Go duration like 1m30s
+def duration(value):
+    units = {"h": 3600, "m": 60, "s": 1, "ms": 1e-3, "us": 1e-6, "µs": 1e-6, "ns": 1e-9}
+    return sum(float(number) * units[unit] for number, unit in re.findall(r"([0-9.]+)(h|ms|us|µs|ns|m|s)", value))
+
+def burn():
+    while True:
+        pass
+
+parser = argparse.ArgumentParser()
+parser.add_argument("--cpu", type=int, default=0)
+parser.add_argument("--memory", type=quantity, default=0)
+parser.add_argument("--duration", type=duration, default=0)
+parser.add_argument("--ramp", type=duration, default=0)
+parser.add_argument("--leak", action="store_true")
+args = parser.parse_args()
+
+chunk = 2**20
+# leaking continues at the ramp-up rate, or the memory target per minute without a ramp-up
+leak_rate = args.memory / (args.ramp or 60)
+workers, memory = [], []
+start = time.time()
+while not args.duration or time.time() - start < args.duration:
+    elapsed = time.time() - start
+    progress = min(1.0, elapsed / args.ramp) if args.ramp else 1.0
+    while len(workers) < round(args.cpu * progress):
+        worker = multiprocessing.Process(target=burn, daemon=True)
+        worker.start()
+        workers.append(worker)
+    target = args.memory * progress
+    if args.leak and progress >= 1:
+        target = args.memory + leak_rate * (elapsed - args.ramp)
+    while len(memory) * chunk < target:
+        # bytes are written when allocated so the pages are resident
+        memory.append(b"\x01" * chunk)
+    print(f"{elapsed:.0f}s: {len(workers)} cpu processes, {len(memory)}Mi memory", flush=True)
+    time.sleep(1)
+for worker in workers:
+    worker.terminate()
+memory.clear()
+print("stress finished", flush=True)
+while True:
+    time.sleep(3600)
+`
+
+// StressArgs generates the arguments of StressScript from the options
+func StressArgs(stress StressOptions) []string {
+	args := []string{"-c", StressScript, fmt.Sprintf("--cpu=%d", stress.CPU)}
+	if stress.Memory != nil {
+		args = append(args, fmt.Sprintf("--memory=%s", stress.Memory))
+	}
+	if stress.Duration > 0 {
+		args = append(args, fmt.Sprintf("--duration=%s", stress.Duration))
+	}
+	if stress.RampUp > 0 {
+		args = append(args, fmt.Sprintf("--ramp=%s", stress.RampUp))
+	}
+	if stress.Leak {
+		args = append(args, "--leak")
+	}
+	return args
+}
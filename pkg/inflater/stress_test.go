@@ -0,0 +1,100 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"fmt"
+	"math"
+	"os/exec"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestStressArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		stress StressOptions
+		want   []string
+	}{
+		{name: "cpu only", stress: StressOptions{CPU: 2}, want: []string{"--cpu=2"}},
+		{
+			name: "all options",
+			stress: StressOptions{
+				CPU:      1,
+				Memory:   lo.ToPtr(resource.MustParse("1Gi")),
+				Duration: 10 * time.Minute,
+				RampUp:   90 * time.Second,
+				Leak:     true,
+			},
+			want: []string{"--cpu=1", "--memory=1Gi", "--duration=10m0s", "--ramp=1m30s", "--leak"},
+		},
+		{
+			name:   "the memory limit is not an argument",
+			stress: StressOptions{Memory: lo.ToPtr(resource.MustParse("256Mi")), MemoryLimit: lo.ToPtr(resource.MustParse("512Mi"))},
+			want:   []string{"--cpu=0", "--memory=256Mi"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			args := StressArgs(tc.stress)
+			if len(args) < 2 || args[0] != "-c" || args[1] != StressScript {
+				t.Fatalf("StressArgs() = %q, want the script first", args)
+			}
+			if got := args[2:]; !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("StressArgs() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// TestStressScriptParsing checks that the script parses quantities and durations like resource.Quantity and time.Duration
+func TestStressScriptParsing(t *testing.T) {
+	python, err := exec.LookPath("python3")
+	if err != nil {
+		t.Skip("python3 is not installed")
+	}
+	// the definitions before the stress loop
+	definitions := StressScript[:strings.Index(StressScript, "def burn():")]
+	run := func(function string, values []string) []string {
+		t.Helper()
+		script := definitions + fmt.Sprintf("import sys\nfor value in sys.argv[1:]:\n    print(%s(value))\n", function)
+		out, err := exec.Command(python, append([]string{"-c", script}, values...)...).CombinedOutput()
+		if err != nil {
+			t.Fatalf("running %s, %v\n%s", function, err, out)
+		}
+		return strings.Fields(string(out))
+	}
+
+	quantities := []string{"1", "256", "500m", "1500m", "1k", "1.5M", "2G", "1T", "1P", "1E", "1Ki", "256Mi", "1.5Gi", "1Ti", "1Pi", "1Ei", "1e3", "1E6", "12e-1"}
+	for idx, got := range run("quantity", quantities) {
+		want := resource.MustParse(quantities[idx])
+		if got != strconv.FormatInt(want.Value(), 10) {
+			t.Errorf("quantity(%q) = %s, want %d", quantities[idx], got, want.Value())
+		}
+	}
+
+	durations := []time.Duration{time.Hour + 30*time.Minute, 90 * time.Second, 1500 * time.Millisecond, 250 * time.Microsecond, 10 * time.Nanosecond}
+	for idx, got := range run("duration", lo.Map(durations, func(duration time.Duration, _ int) string { return duration.String() })) {
+		seconds, err := strconv.ParseFloat(got, 64)
+		if want := durations[idx].Seconds(); err != nil || math.Abs(seconds-want) > want*1e-9 {
+			t.Errorf("duration(%q) = %s, want %v", durations[idx], got, want)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/samber/lo"
+	"go.uber.org/multierr"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	ReasonInsufficientCPU    = "insufficient cpu"
+	ReasonInsufficientMemory = "insufficient memory"
+	ReasonTaint              = "taint mismatch"
+	ReasonNodeSelector       = "node selector/affinity mismatch"
+	ReasonTopologySpread     = "topology spread"
+	ReasonHostPort           = "host port conflict"
+	ReasonPodAffinity        = "pod affinity"
+	ReasonPodAntiAffinity    = "pod anti-affinity"
+	ReasonUnschedulableNode  = "node unschedulable"
+	ReasonTooManyPods        = "too many pods"
+	ReasonVolume             = "volume"
+)
+
+var schedulingMessageRegex = regexp.MustCompile(`^\d+/(\d+) nodes are available: (.*)$`)
+
+// SchedulingReason is the number of nodes the scheduler rejected for a categorized reason
+type SchedulingReason struct {
+	Category string
+	Nodes    int
+	Details  []string
+}
+
+// SchedulingDiagnosis explains why an inflate's pods are pending
+type SchedulingDiagnosis struct {
+	Namespace   string
+	Name        string
+	PendingPods int
+	// FailedSchedulingPods is the number of pending pods with FailedScheduling events
+	FailedSchedulingPods int
+	// LatestMessage is the most recent FailedScheduling message, the reasons are parsed from it
+	LatestMessage string
+	TotalNodes    int
+	Reasons       []SchedulingReason
+	LikelyCauses  []string
+}
+
+// Why diagnoses the pending pods of the inflates matching filters from their FailedScheduling events
+func (i Inflater) Why(ctx context.Context, filters ListFilters) ([]SchedulingDiagnosis, error) {
+	deployments, errs := i.List(ctx, filters)
+	if len(deployments) == 0 && errs == nil {
+		return nil, &NotFoundError{Namespace: filters.Namespace, Name: filters.Name}
+	}
+	var diagnoses []SchedulingDiagnosis
+	for _, deployment := range deployments {
+		diagnosis, err := i.diagnose(ctx, deployment)
+		if err != nil {
+			errs = multierr.Append(errs, err)
+			continue
+		}
+		diagnoses = append(diagnoses, diagnosis)
+	}
+	return diagnoses, errs
+}
+
+func (i Inflater) diagnose(ctx context.Context, deployment appsv1.Deployment) (SchedulingDiagnosis, error) {
+	diagnosis := SchedulingDiagnosis{Namespace: deployment.Namespace, Name: deployment.Name}
+	podList, err := i.clientset.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s,managed-by=inflate", deployment.Name),
+	})
+	if err != nil {
+		return diagnosis, err
+	}
+	pendingPods := map[string]struct{}{}
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodPending && pod.DeletionTimestamp == nil {
+			pendingPods[pod.Name] = struct{}{}
+		}
+	}
+	diagnosis.PendingPods = len(pendingPods)
+	if len(pendingPods) == 0 {
+		return diagnosis, nil
+	}
+	eventList, err := i.clientset.CoreV1().Events(deployment.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: "reason=FailedScheduling,involvedObject.kind=Pod",
+	})
+	if err != nil {
+		return diagnosis, err
+	}
+	var latest *corev1.Event
+	failedPods := map[string]struct{}{}
+	for idx := range eventList.Items {
+		event := &eventList.Items[idx]
+		if _, ok := pendingPods[event.InvolvedObject.Name]; !ok {
+			continue
+		}
+		failedPods[event.InvolvedObject.Name] = struct{}{}
+		if latest == nil || eventTime(*event).After(eventTime(*latest)) {
+			latest = event
+		}
+	}
+	diagnosis.FailedSchedulingPods = len(failedPods)
+	if latest == nil {
+		return diagnosis, nil
+	}
+	diagnosis.LatestMessage = latest.Message
+	diagnosis.TotalNodes, diagnosis.Reasons = ParseSchedulingMessage(latest.Message)
+	diagnosis.LikelyCauses = likelyCauses(deployment, diagnosis.Reasons)
+	return diagnosis, nil
+}
+
+// ParseSchedulingMessage parses a scheduler message like
+// "0/5 nodes are available: 3 Insufficient cpu, 2 node(s) didn't match pod topology spread constraints."
+// into the total number of nodes and the categorized reasons, ordered by the number of nodes
+func ParseSchedulingMessage(message string) (int, []SchedulingReason) {
+	// drop the preemption summary the scheduler appends
+	message, _, _ = strings.Cut(message, " preemption:")
+	matches := schedulingMessageRegex.FindStringSubmatch(strings.TrimSpace(message))
+	if matches == nil {
+		return 0, nil
+	}
+	totalNodes, _ := strconv.Atoi(matches[1])
+	reasons := map[string]*SchedulingReason{}
+	for _, item := range splitSchedulingReasons(strings.TrimSuffix(matches[2], ".")) {
+		countStr, detail, found := strings.Cut(item, " ")
+		count, err := strconv.Atoi(countStr)
+		if !found || err != nil {
+			continue
+		}
+		category := categorizeSchedulingReason(detail)
+		if _, ok := reasons[category]; !ok {
+			reasons[category] = &SchedulingReason{Category: category}
+		}
+		reasons[category].Nodes += count
+		reasons[category].Details = append(reasons[category].Details, detail)
+	}
+	sortedReasons := lo.Map(lo.Values(reasons), func(reason *SchedulingReason, _ int) SchedulingReason { return *reason })
+	sort.SliceStable(sortedReasons, func(i, j int) bool {
+		if sortedReasons[i].Nodes == sortedReasons[j].Nodes {
+			return sortedReasons[i].Category < sortedReasons[j].Category
+		}
+		return sortedReasons[i].Nodes > sortedReasons[j].Nodes
+	})
+	return totalNodes, sortedReasons
+}
+
+// splitSchedulingReasons splits on ", " only where the next reason starts with a node count,
+// since taint details may contain commas
+func splitSchedulingReasons(reasons string) []string {
+	var items []string
+	for _, part := range strings.Split(reasons, ", ") {
+		if len(items) > 0 && (part == "" || part[0] < '0' || part[0] > '9') {
+			items[len(items)-1] += ", " + part
+			continue
+		}
+		items = append(items, part)
+	}
+	return items
+}
+
+func categorizeSchedulingReason(detail string) string {
+	lower := strings.ToLower(detail)
+	switch {
+	case strings.HasPrefix(lower, "insufficient cpu"):
+		return ReasonInsufficientCPU
+	case strings.HasPrefix(lower, "insufficient memory"):
+		return ReasonInsufficientMemory
+	case strings.HasPrefix(lower, "insufficient "):
+		return lower
+	case strings.Contains(lower, "taint"):
+		return ReasonTaint
+	case strings.Contains(lower, "node affinity/selector"):
+		return ReasonNodeSelector
+	case strings.Contains(lower, "topology spread"):
+		return ReasonTopologySpread
+	case strings.Contains(lower, "free ports"):
+		return ReasonHostPort
+	case strings.Contains(lower, "anti-affinity"):
+		return ReasonPodAntiAffinity
+	case strings.Contains(lower, "pod affinity"):
+		return ReasonPodAffinity
+	case strings.Contains(lower, "were unschedulable"):
+		return ReasonUnschedulableNode
+	case strings.Contains(lower, "too many pods"):
+		return ReasonTooManyPods
+	case strings.Contains(lower, "volume"):
+		return ReasonVolume
+	default:
+		return detail
+	}
+}
+
+// likelyCauses relates the scheduling reasons to the inflate options that produced the deployment
+func likelyCauses(deployment appsv1.Deployment, reasons []SchedulingReason) []string {
+	podSpec := deployment.Spec.Template.Spec
+	var causes []string
+	for _, reason := range reasons {
+		switch reason.Category {
+		case ReasonInsufficientCPU, ReasonInsufficientMemory:
+			for _, container := range podSpec.Containers {
+				causes = append(causes, fmt.Sprintf("%s: each pod requests cpu=%s memory=%s", reason.Category,
+					container.Resources.Requests.Cpu(), container.Resources.Requests.Memory()))
+			}
+		case ReasonNodeSelector:
+			flags := map[string]string{"kubernetes.io/arch": "--cpu-arch", "kubernetes.io/os": "--os", LabelNodePool: "--nodepool",
+				LabelCapacityType: "--capacity-type", corev1.LabelInstanceTypeStable: "--instance-type", LabelInstanceFamily: "--instance-family"}
+			for _, key := range lo.Keys(podSpec.NodeSelector) {
+				causes = append(causes, fmt.Sprintf("%s: nodeSelector %s=%s (%s)", reason.Category, key, podSpec.NodeSelector[key],
+					lo.ValueOr(flags, key, "nodeSelector")))
+			}
+		case ReasonTopologySpread:
+			flags := map[string]string{corev1.LabelTopologyZone: "--zonal-spread", corev1.LabelHostname: "--hostname-spread", LabelCapacityType: "--capacity-type-spread"}
+			for _, constraint := range podSpec.TopologySpreadConstraints {
+				causes = append(causes, fmt.Sprintf("%s: %s spread on %s with maxSkew %d (%s)", reason.Category,
+					constraint.WhenUnsatisfiable, constraint.TopologyKey, constraint.MaxSkew, lo.ValueOr(flags, constraint.TopologyKey, "topologySpreadConstraints")))
+			}
+		case ReasonPodAntiAffinity:
+			for _, term := range antiAffinityTerms(podSpec.Affinity) {
+				causes = append(causes, fmt.Sprintf("%s: required pod anti-affinity allows one matching pod per %s (--anti-affinity)", reason.Category, term.TopologyKey))
+			}
+		case ReasonTaint:
+			if len(podSpec.Tolerations) == 0 {
+				causes = append(causes, fmt.Sprintf("%s: pods have no tolerations for the tainted nodes", reason.Category))
+			} else {
+				causes = append(causes, fmt.Sprintf("%s: the pod tolerations do not match the taints of the nodes (--tolerations)", reason.Category))
+			}
+		}
+	}
+	sort.Strings(causes)
+	return lo.Uniq(causes)
+}
@@ -0,0 +1,133 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package inflater
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+func TestParseSchedulingMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name        string
+		message     string
+		wantNodes   int
+		wantReasons []SchedulingReason
+	}{
+		{
+			name:        "not a scheduling message",
+			message:     "pod has unbound immediate PersistentVolumeClaims. preemption: 0/3 nodes are available: 3 Preemption is not helpful for scheduling..",
+			wantReasons: nil,
+		},
+		{
+			name:      "insufficient cpu with a preemption summary",
+			message:   "0/3 nodes are available: 3 Insufficient cpu. preemption: 0/3 nodes are available: 3 No preemption victims found for incoming pod..",
+			wantNodes: 3,
+			wantReasons: []SchedulingReason{
+				{Category: ReasonInsufficientCPU, Nodes: 3, Details: []string{"Insufficient cpu"}},
+			},
+		},
+		{
+			name: "ordered by nodes then category",
+			message: "0/5 nodes are available: 1 node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }, 2 Insufficient memory, " +
+				"2 node(s) didn't match Pod's node affinity/selector. preemption: 0/5 nodes are available: 2 No preemption victims found for incoming pod, " +
+				"3 Preemption is not helpful for scheduling..",
+			wantNodes: 5,
+			wantReasons: []SchedulingReason{
+				{Category: ReasonInsufficientMemory, Nodes: 2, Details: []string{"Insufficient memory"}},
+				{Category: ReasonNodeSelector, Nodes: 2, Details: []string{"node(s) didn't match Pod's node affinity/selector"}},
+				{Category: ReasonTaint, Nodes: 1, Details: []string{"node(s) had untolerated taint {node-role.kubernetes.io/control-plane: }"}},
+			},
+		},
+		{
+			name:      "taint details with commas",
+			message:   "0/2 nodes are available: 1 node(s) had taint {node.kubernetes.io/not-ready: }, that the pod didn't tolerate, 1 node(s) had untolerated taint {dedicated: batch}.",
+			wantNodes: 2,
+			wantReasons: []SchedulingReason{
+				{Category: ReasonTaint, Nodes: 2, Details: []string{
+					"node(s) had taint {node.kubernetes.io/not-ready: }, that the pod didn't tolerate",
+					"node(s) had untolerated taint {dedicated: batch}",
+				}},
+			},
+		},
+		{
+			name: "spread, anti-affinity, ports, volumes and unschedulable nodes",
+			message: "0/9 nodes are available: 3 node(s) didn't match pod topology spread constraints, 2 node(s) didn't match pod anti-affinity rules, " +
+				"1 node(s) didn't have free ports for the requested pod ports, 1 node(s) had volume node affinity conflict, 1 node(s) were unschedulable, 1 Too many pods.",
+			wantNodes: 9,
+			wantReasons: []SchedulingReason{
+				{Category: ReasonTopologySpread, Nodes: 3, Details: []string{"node(s) didn't match pod topology spread constraints"}},
+				{Category: ReasonPodAntiAffinity, Nodes: 2, Details: []string{"node(s) didn't match pod anti-affinity rules"}},
+				{Category: ReasonHostPort, Nodes: 1, Details: []string{"node(s) didn't have free ports for the requested pod ports"}},
+				{Category: ReasonUnschedulableNode, Nodes: 1, Details: []string{"node(s) were unschedulable"}},
+				{Category: ReasonTooManyPods, Nodes: 1, Details: []string{"Too many pods"}},
+				{Category: ReasonVolume, Nodes: 1, Details: []string{"node(s) had volume node affinity conflict"}},
+			},
+		},
+		{
+			name:      "extended resources",
+			message:   "0/4 nodes are available: 4 Insufficient nvidia.com/gpu.",
+			wantNodes: 4,
+			wantReasons: []SchedulingReason{
+				{Category: "insufficient nvidia.com/gpu", Nodes: 4, Details: []string{"Insufficient nvidia.com/gpu"}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			nodes, reasons := ParseSchedulingMessage(tc.message)
+			if nodes != tc.wantNodes {
+				t.Errorf("ParseSchedulingMessage() nodes = %d, want %d", nodes, tc.wantNodes)
+			}
+			if !reflect.DeepEqual(reasons, tc.wantReasons) {
+				t.Errorf("ParseSchedulingMessage() reasons = %+v, want %+v", reasons, tc.wantReasons)
+			}
+		})
+	}
+}
+
+func TestLikelyCauses(t *testing.T) {
+	deployment := appsv1.Deployment{}
+	deployment.Spec.Template.Spec = corev1.PodSpec{
+		HostNetwork:  true,
+		NodeSelector: map[string]string{"kubernetes.io/arch": "arm64"},
+		TopologySpreadConstraints: []corev1.TopologySpreadConstraint{
+			{MaxSkew: 1, TopologyKey: corev1.LabelTopologyZone, WhenUnsatisfiable: corev1.DoNotSchedule},
+		},
+		Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: []corev1.PodAffinityTerm{{TopologyKey: corev1.LabelHostname}},
+		}},
+	}
+	reasons := []SchedulingReason{
+		{Category: ReasonNodeSelector}, {Category: ReasonTopologySpread}, {Category: ReasonTaint}, {Category: ReasonHostPort}, {Category: ReasonPodAntiAffinity},
+	}
+	want := []string{
+		"node selector/affinity mismatch: nodeSelector kubernetes.io/arch=arm64 (--cpu-arch)",
+		"pod anti-affinity: required pod anti-affinity allows one matching pod per kubernetes.io/hostname (--anti-affinity)",
+		"taint mismatch: pods have no tolerations for the tainted nodes",
+		"topology spread: DoNotSchedule spread on topology.kubernetes.io/zone with maxSkew 1 (--zonal-spread)",
+	}
+	if got := likelyCauses(deployment, reasons); !reflect.DeepEqual(got, want) {
+		t.Errorf("likelyCauses() = %q, want %q", got, want)
+	}
+
+	deployment.Spec.Template.Spec.Tolerations = []corev1.Toleration{{Key: "gpu", Operator: corev1.TolerationOpExists}}
+	want = []string{"taint mismatch: the pod tolerations do not match the taints of the nodes (--tolerations)"}
+	if got := likelyCauses(deployment, []SchedulingReason{{Category: ReasonTaint}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("likelyCauses() with tolerations = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,320 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Recorder observes inflate pods, nodes and events while a command runs
+type Recorder struct {
+	clientset kubernetes.Interface
+	// namespaces are where the events are watched, the namespaces of the inflates
+	namespaces []string
+	startTime  time.Time
+	cancel     context.CancelFunc
+
+	mu     sync.Mutex
+	pods   map[types.UID]*PodTimeline
+	nodes  map[string]*NodeTimeline
+	events map[types.UID]corev1.Event
+}
+
+// NewRecorder returns a recorder of the events in the namespaces, events about cluster-scoped objects are not recorded
+func NewRecorder(clientset kubernetes.Interface, namespaces []string) *Recorder {
+	return &Recorder{
+		clientset:  clientset,
+		namespaces: lo.Uniq(namespaces),
+		pods:       map[types.UID]*PodTimeline{},
+		nodes:      map[string]*NodeTimeline{},
+		events:     map[types.UID]corev1.Event{},
+	}
+}
+
+// Start runs the informers until Stop is called, it returns once their caches are synced
+func (r *Recorder) Start(ctx context.Context) error {
+	ctx, r.cancel = context.WithCancel(ctx)
+	r.startTime = time.Now()
+	podFactory := informers.NewSharedInformerFactoryWithOptions(r.clientset, 0,
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = "managed-by=inflate"
+		}),
+	)
+	factory := informers.NewSharedInformerFactory(r.clientset, 0)
+	factories := []informers.SharedInformerFactory{podFactory, factory}
+	if _, err := podFactory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { r.updatePod(obj, false) },
+		UpdateFunc: func(_, obj any) { r.updatePod(obj, false) },
+		DeleteFunc: func(obj any) { r.updatePod(obj, true) },
+	}); err != nil {
+		return err
+	}
+	if _, err := factory.Core().V1().Nodes().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { r.updateNode(obj, false) },
+		UpdateFunc: func(_, obj any) { r.updateNode(obj, false) },
+		DeleteFunc: func(obj any) { r.updateNode(obj, true) },
+	}); err != nil {
+		return err
+	}
+	// events are only watched in the inflate namespaces, the events of a whole cluster would pile up for the entire run
+	for _, namespace := range r.namespaces {
+		eventFactory := informers.NewSharedInformerFactoryWithOptions(r.clientset, 0, informers.WithNamespace(namespace))
+		if _, err := eventFactory.Core().V1().Events().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    func(obj any) { r.updateEvent(obj) },
+			UpdateFunc: func(_, obj any) { r.updateEvent(obj) },
+		}); err != nil {
+			return err
+		}
+		factories = append(factories, eventFactory)
+	}
+	for _, f := range factories {
+		f.Start(ctx.Done())
+	}
+	for _, f := range factories {
+		for informerType, synced := range f.WaitForCacheSync(ctx.Done()) {
+			if !synced {
+				return fmt.Errorf("failed to sync informer for %v", informerType)
+			}
+		}
+	}
+	return nil
+}
+
+// Stop stops the informers
+func (r *Recorder) Stop() {
+	if r.cancel != nil {
+		r.cancel()
+	}
+}
+
+// Wait polls condition with the recorded pods until it is true, returning false if ctx is done first
+func (r *Recorder) Wait(ctx context.Context, condition func(pods []PodTimeline) bool) bool {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		if condition(r.Pods()) {
+			return true
+		}
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// Pods returns a copy of the recorded pod timelines
+func (r *Recorder) Pods() []PodTimeline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pods := lo.Map(lo.Values(r.pods), func(pod *PodTimeline, _ int) PodTimeline { return *pod })
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Created.Before(pods[j].Created) })
+	return pods
+}
+
+// Nodes returns the recorded node timelines with the time the first inflate pod was bound to each
+func (r *Recorder) Nodes() []NodeTimeline {
+	return nodesWithPods(r.nodeTimelines(), r.Pods())
+}
+
+func (r *Recorder) nodeTimelines() []NodeTimeline {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	nodes := lo.Map(lo.Values(r.nodes), func(node *NodeTimeline, _ int) NodeTimeline { return *node })
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Created.Before(nodes[j].Created) })
+	return nodes
+}
+
+func nodesWithPods(nodes []NodeTimeline, pods []PodTimeline) []NodeTimeline {
+	for idx := range nodes {
+		for _, pod := range pods {
+			if pod.Node != nodes[idx].Name || pod.Scheduled == nil {
+				continue
+			}
+			if nodes[idx].FirstPodBound == nil || pod.Scheduled.Before(*nodes[idx].FirstPodBound) {
+				nodes[idx].FirstPodBound = pod.Scheduled
+			}
+		}
+	}
+	return nodes
+}
+
+// Report assembles the report, keeping only the pods of the deployments in resources
+// and the events since the recorder started about resources, their pods and replica sets
+func (r *Recorder) Report(command string, options any, resources []Resource) Report {
+	pods := lo.Filter(r.Pods(), func(pod PodTimeline, _ int) bool {
+		return lo.ContainsBy(resources, func(resource Resource) bool {
+			return resource.Kind == "Deployment" && resource.Namespace == pod.Namespace && resource.Name == pod.Inflate
+		})
+	})
+	nodes := nodesWithPods(r.nodeTimelines(), pods)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	report := Report{
+		SchemaVersion: SchemaVersion,
+		Command:       command,
+		StartTime:     r.startTime,
+		EndTime:       time.Now(),
+		Options:       options,
+		Resources:     resources,
+		Pods:          pods,
+		Nodes:         nodes,
+	}
+	podUIDs := lo.SliceToMap(pods, func(pod PodTimeline) (types.UID, struct{}) { return pod.UID, struct{}{} })
+	for _, event := range r.events {
+		if eventTime(event).Before(r.startTime.Truncate(time.Second)) || !involvesManagedObject(event, resources, podUIDs) {
+			continue
+		}
+		report.Events = append(report.Events, Event{
+			Time:    eventTime(event),
+			Type:    event.Type,
+			Reason:  event.Reason,
+			Message: event.Message,
+			Count:   event.Count,
+			InvolvedObject: Resource{
+				Kind:      event.InvolvedObject.Kind,
+				Namespace: event.InvolvedObject.Namespace,
+				Name:      event.InvolvedObject.Name,
+				UID:       event.InvolvedObject.UID,
+			},
+		})
+	}
+	sort.SliceStable(report.Events, func(i, j int) bool { return report.Events[i].Time.Before(report.Events[j].Time) })
+	return report
+}
+
+func involvesManagedObject(event corev1.Event, resources []Resource, podUIDs map[types.UID]struct{}) bool {
+	involved := event.InvolvedObject
+	if _, ok := podUIDs[involved.UID]; ok {
+		return true
+	}
+	for _, resource := range resources {
+		if involved.UID != "" && involved.UID == resource.UID {
+			return true
+		}
+		if resource.Kind == "Deployment" && involved.Kind == "ReplicaSet" && involved.Namespace == resource.Namespace &&
+			strings.HasPrefix(involved.Name, resource.Name+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recorder) updatePod(obj any, deleted bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timeline, ok := r.pods[pod.UID]
+	if !ok {
+		timeline = &PodTimeline{
+			Namespace: pod.Namespace,
+			Name:      pod.Name,
+			UID:       pod.UID,
+			Inflate:   pod.Labels["app"],
+			Created:   pod.CreationTimestamp.Time,
+		}
+		r.pods[pod.UID] = timeline
+	}
+	timeline.Node = pod.Spec.NodeName
+	for _, condition := range pod.Status.Conditions {
+		if condition.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch condition.Type {
+		case corev1.PodScheduled:
+			timeline.Scheduled = lo.ToPtr(condition.LastTransitionTime.Time)
+		case corev1.PodReady:
+			timeline.Ready = lo.ToPtr(condition.LastTransitionTime.Time)
+		}
+	}
+	if deleted && timeline.Deleted == nil {
+		timeline.Deleted = lo.ToPtr(time.Now())
+	}
+}
+
+// updateNode tracks nodes created after the recorder started and nodes deleted while it runs
+func (r *Recorder) updateNode(obj any, deleted bool) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	node, ok := obj.(*corev1.Node)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	timeline, ok := r.nodes[node.Name]
+	if !ok {
+		if !deleted && node.CreationTimestamp.Time.Before(r.startTime.Truncate(time.Second)) {
+			return
+		}
+		timeline = &NodeTimeline{
+			Name:         node.Name,
+			InstanceType: node.Labels[corev1.LabelInstanceTypeStable],
+			Zone:         node.Labels[corev1.LabelTopologyZone],
+			CapacityType: node.Labels["karpenter.sh/capacity-type"],
+			Created:      node.CreationTimestamp.Time,
+		}
+		r.nodes[node.Name] = timeline
+	}
+	for _, condition := range node.Status.Conditions {
+		if condition.Type == corev1.NodeReady && condition.Status == corev1.ConditionTrue && timeline.Ready == nil {
+			timeline.Ready = lo.ToPtr(condition.LastTransitionTime.Time)
+		}
+	}
+	if deleted && timeline.Deleted == nil {
+		timeline.Deleted = lo.ToPtr(time.Now())
+	}
+}
+
+func (r *Recorder) updateEvent(obj any) {
+	event, ok := obj.(*corev1.Event)
+	if !ok {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[event.UID] = *event
+}
+
+func eventTime(event corev1.Event) time.Time {
+	switch {
+	case !event.LastTimestamp.IsZero():
+		return event.LastTimestamp.Time
+	case !event.EventTime.IsZero():
+		return event.EventTime.Time
+	default:
+		return event.CreationTimestamp.Time
+	}
+}
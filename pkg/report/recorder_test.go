@@ -0,0 +1,226 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/cache"
+)
+
+func TestInvolvesManagedObject(t *testing.T) {
+	resources := []Resource{
+		{Kind: "Deployment", Namespace: "inflate", Name: "web", UID: "deployment-uid"},
+		{Kind: "Service", Namespace: "inflate", Name: "web", UID: "service-uid"},
+	}
+	podUIDs := map[types.UID]struct{}{"pod-uid": {}}
+	for _, tc := range []struct {
+		name     string
+		involved corev1.ObjectReference
+		want     bool
+	}{
+		{name: "recorded pod", involved: corev1.ObjectReference{Kind: "Pod", Namespace: "inflate", Name: "web-abc-123", UID: "pod-uid"}, want: true},
+		{name: "other pod", involved: corev1.ObjectReference{Kind: "Pod", Namespace: "inflate", Name: "web-abc-456", UID: "other-uid"}},
+		{name: "resource by uid", involved: corev1.ObjectReference{Kind: "Service", Namespace: "inflate", Name: "web", UID: "service-uid"}, want: true},
+		{name: "resource without uid", involved: corev1.ObjectReference{Kind: "Service", Namespace: "inflate", Name: "web"}},
+		{name: "replica set of a deployment", involved: corev1.ObjectReference{Kind: "ReplicaSet", Namespace: "inflate", Name: "web-7d4b9c"}, want: true},
+		{name: "replica set in another namespace", involved: corev1.ObjectReference{Kind: "ReplicaSet", Namespace: "other", Name: "web-7d4b9c"}},
+		{name: "replica set of another deployment", involved: corev1.ObjectReference{Kind: "ReplicaSet", Namespace: "inflate", Name: "webapp"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := involvesManagedObject(corev1.Event{InvolvedObject: tc.involved}, resources, podUIDs); got != tc.want {
+				t.Errorf("involvesManagedObject() = %t, want %t", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpdatePod(t *testing.T) {
+	created := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: "web-1", UID: "uid", Labels: map[string]string{"app": "web"}, CreationTimestamp: metav1.NewTime(created)},
+	}
+	r := NewRecorder(nil, nil)
+	r.updatePod(pod, false)
+	want := PodTimeline{Namespace: "inflate", Name: "web-1", UID: "uid", Inflate: "web", Created: created}
+	if got := r.Pods(); !reflect.DeepEqual(got, []PodTimeline{want}) {
+		t.Fatalf("Pods() = %+v, want %+v", got, want)
+	}
+
+	pod = pod.DeepCopy()
+	pod.Spec.NodeName = "node-1"
+	pod.Status.Conditions = []corev1.PodCondition{
+		{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(created.Add(time.Second))},
+		{Type: corev1.PodReady, Status: corev1.ConditionFalse, LastTransitionTime: metav1.NewTime(created.Add(time.Second))},
+	}
+	r.updatePod(pod, false)
+	want.Node, want.Scheduled = "node-1", lo.ToPtr(created.Add(time.Second))
+	if got := r.Pods(); !reflect.DeepEqual(got, []PodTimeline{want}) {
+		t.Fatalf("Pods() = %+v, want %+v", got, want)
+	}
+
+	pod.Status.Conditions[1] = corev1.PodCondition{Type: corev1.PodReady, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(created.Add(5 * time.Second))}
+	r.updatePod(cache.DeletedFinalStateUnknown{Key: "inflate/web-1", Obj: pod}, true)
+	got := r.Pods()
+	if len(got) != 1 || got[0].Deleted == nil {
+		t.Fatalf("Pods() = %+v, want the pod deleted", got)
+	}
+	want.Ready, want.Deleted = lo.ToPtr(created.Add(5*time.Second)), got[0].Deleted
+	if !reflect.DeepEqual(got, []PodTimeline{want}) {
+		t.Errorf("Pods() = %+v, want %+v", got, want)
+	}
+}
+
+func TestUpdateNode(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 500_000_000, time.UTC)
+	node := func(name string, created time.Time) *corev1.Node {
+		return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:              name,
+			CreationTimestamp: metav1.NewTime(created),
+			Labels: map[string]string{
+				corev1.LabelInstanceTypeStable: "m5.large",
+				corev1.LabelTopologyZone:       "us-west-2a",
+				"karpenter.sh/capacity-type":   "spot",
+			},
+		}}
+	}
+	r := NewRecorder(nil, nil)
+	r.startTime = start
+
+	// nodes that existed before the start are only recorded when they are deleted
+	old := node("old", start.Add(-time.Hour))
+	r.updateNode(old, false)
+	if got := r.nodeTimelines(); len(got) != 0 {
+		t.Fatalf("nodeTimelines() = %+v, want no nodes", got)
+	}
+	r.updateNode(cache.DeletedFinalStateUnknown{Key: "old", Obj: old}, true)
+
+	// a node created in the start second is recorded
+	added := node("added", start.Truncate(time.Second))
+	r.updateNode(added, false)
+	added = added.DeepCopy()
+	added.Status.Conditions = []corev1.NodeCondition{{Type: corev1.NodeReady, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(start.Add(time.Minute))}}
+	r.updateNode(added, false)
+	// the first Ready transition is kept
+	added = added.DeepCopy()
+	added.Status.Conditions[0].LastTransitionTime = metav1.NewTime(start.Add(time.Hour))
+	r.updateNode(added, false)
+
+	got := r.nodeTimelines()
+	if len(got) != 2 || got[0].Deleted == nil {
+		t.Fatalf("nodeTimelines() = %+v, want the old node deleted and the added node", got)
+	}
+	want := []NodeTimeline{
+		{Name: "old", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Created: start.Add(-time.Hour), Deleted: got[0].Deleted},
+		{Name: "added", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Created: start.Truncate(time.Second), Ready: lo.ToPtr(start.Add(time.Minute))},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("nodeTimelines() = %+v, want %+v", got, want)
+	}
+}
+
+func TestReport(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 500_000_000, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Truncate(time.Second).Add(time.Duration(seconds) * time.Second)
+	}
+	r := NewRecorder(nil, nil)
+	r.startTime = start
+	r.pods = map[types.UID]*PodTimeline{
+		"web-1": {Namespace: "inflate", Name: "web-1", UID: "web-1", Inflate: "web", Node: "added", Created: at(1), Scheduled: lo.ToPtr(at(20))},
+		"web-2": {Namespace: "inflate", Name: "web-2", UID: "web-2", Inflate: "web", Node: "added", Created: at(2), Scheduled: lo.ToPtr(at(10))},
+		"cache": {Namespace: "inflate", Name: "cache-1", UID: "cache", Inflate: "cache", Node: "added", Created: at(1), Scheduled: lo.ToPtr(at(5))},
+		"other": {Namespace: "other", Name: "web-1", UID: "other", Inflate: "web", Created: at(1)},
+	}
+	r.nodes = map[string]*NodeTimeline{"added": {Name: "added", Created: at(0)}}
+	event := func(uid types.UID, seconds int, reason string, involved corev1.ObjectReference) corev1.Event {
+		return corev1.Event{ObjectMeta: metav1.ObjectMeta{UID: uid}, LastTimestamp: metav1.NewTime(at(seconds)), Reason: reason, InvolvedObject: involved}
+	}
+	for _, e := range []corev1.Event{
+		event("late", 30, "Started", corev1.ObjectReference{Kind: "Pod", Namespace: "inflate", Name: "web-1", UID: "web-1"}),
+		event("early", 10, "ScalingReplicaSet", corev1.ObjectReference{Kind: "Deployment", Namespace: "inflate", Name: "web", UID: "deployment"}),
+		event("before", -10, "ScalingReplicaSet", corev1.ObjectReference{Kind: "Deployment", Namespace: "inflate", Name: "web", UID: "deployment"}),
+		event("unmanaged", 20, "Started", corev1.ObjectReference{Kind: "Pod", Namespace: "inflate", Name: "cache-1", UID: "cache"}),
+	} {
+		r.events[e.UID] = e
+	}
+
+	resources := []Resource{{Kind: "Deployment", Namespace: "inflate", Name: "web", UID: "deployment"}}
+	got := r.Report("create", "options", resources)
+	if got.SchemaVersion != SchemaVersion || got.Command != "create" || got.Options != "options" || !got.StartTime.Equal(start) {
+		t.Errorf("Report() = %+v, want the schema version, command, options and start time", got)
+	}
+	if names := lo.Map(got.Pods, func(pod PodTimeline, _ int) string { return pod.Name }); !reflect.DeepEqual(names, []string{"web-1", "web-2"}) {
+		t.Errorf("Report() pods = %v, want the pods of the deployment", names)
+	}
+	if len(got.Nodes) != 1 || got.Nodes[0].FirstPodBound == nil || !got.Nodes[0].FirstPodBound.Equal(at(10)) {
+		t.Errorf("Report() nodes = %+v, want the first pod of the deployment bound at %s", got.Nodes, at(10))
+	}
+	if reasons := lo.Map(got.Events, func(e Event, _ int) string { return e.Reason }); !reflect.DeepEqual(reasons, []string{"ScalingReplicaSet", "Started"}) {
+		t.Errorf("Report() events = %+v, want the managed events since the start in order", got.Events)
+	}
+}
+
+// TestRecorderStart checks that events are only listed and watched in the namespaces of the recorder
+func TestRecorderStart(t *testing.T) {
+	clientset := fake.NewSimpleClientset(
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Namespace: "inflate", Name: "a", UID: "a"}},
+		&corev1.Event{ObjectMeta: metav1.ObjectMeta{Namespace: "other", Name: "b", UID: "b"}},
+	)
+	r := NewRecorder(clientset, []string{"inflate", "inflate"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := r.Start(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+	for _, action := range clientset.Actions() {
+		if action.GetResource().Resource == "events" && action.GetNamespace() != "inflate" {
+			t.Errorf("%s events in namespace %q, want only namespace inflate", action.GetVerb(), action.GetNamespace())
+		}
+	}
+	listed := lo.CountBy(clientset.Actions(), func(action k8stesting.Action) bool {
+		return action.GetResource().Resource == "events" && action.GetVerb() == "list"
+	})
+	if listed != 1 {
+		t.Errorf("events were listed %d times, want once", listed)
+	}
+	for ctx.Err() == nil {
+		r.mu.Lock()
+		_, ok := r.events["a"]
+		r.mu.Unlock()
+		if ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.events["a"]; !ok {
+		t.Error("the event in namespace inflate was not recorded")
+	}
+	if _, ok := r.events["b"]; ok {
+		t.Error("the event in namespace other was recorded")
+	}
+}
@@ -0,0 +1,187 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/samber/lo"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// SchemaVersion is bumped whenever a field of the report is changed or removed
+const SchemaVersion = "inflate.report/v1"
+
+// Report is the machine-readable record of a single inflate run
+type Report struct {
+	SchemaVersion string         `json:"schemaVersion"`
+	Command       string         `json:"command"`
+	StartTime     time.Time      `json:"startTime"`
+	EndTime       time.Time      `json:"endTime"`
+	TimedOut      bool           `json:"timedOut"`
+	Options       any            `json:"options"`
+	Resources     []Resource     `json:"resources"`
+	Pods          []PodTimeline  `json:"pods"`
+	Nodes         []NodeTimeline `json:"nodes"`
+	NodeSummary   []NodeSummary  `json:"nodeSummary"`
+	// TimeToCapacitySeconds is the time from the start of the run until the last pod became ready
+	TimeToCapacitySeconds *float64 `json:"timeToCapacitySeconds,omitempty"`
+	Events                []Event  `json:"events"`
+}
+
+// Resource identifies an object created or deleted by the run
+type Resource struct {
+	Kind      string    `json:"kind"`
+	Namespace string    `json:"namespace,omitempty"`
+	Name      string    `json:"name"`
+	UID       types.UID `json:"uid"`
+}
+
+// PodTimeline records the lifecycle of an inflate pod
+type PodTimeline struct {
+	Namespace string     `json:"namespace"`
+	Name      string     `json:"name"`
+	UID       types.UID  `json:"uid"`
+	Inflate   string     `json:"inflate"`
+	Node      string     `json:"node,omitempty"`
+	Created   time.Time  `json:"created"`
+	Scheduled *time.Time `json:"scheduled,omitempty"`
+	Ready     *time.Time `json:"ready,omitempty"`
+	Deleted   *time.Time `json:"deleted,omitempty"`
+}
+
+// NodeTimeline records the lifecycle of a node created or deleted during the run
+type NodeTimeline struct {
+	Name          string     `json:"name"`
+	InstanceType  string     `json:"instanceType,omitempty"`
+	Zone          string     `json:"zone,omitempty"`
+	CapacityType  string     `json:"capacityType,omitempty"`
+	Created       time.Time  `json:"created"`
+	Ready         *time.Time `json:"ready,omitempty"`
+	FirstPodBound *time.Time `json:"firstPodBound,omitempty"`
+	Deleted       *time.Time `json:"deleted,omitempty"`
+}
+
+// NodeSummary counts the nodes added and removed during the run per instance type, zone and capacity type
+type NodeSummary struct {
+	InstanceType string `json:"instanceType"`
+	Zone         string `json:"zone"`
+	CapacityType string `json:"capacityType"`
+	Added        int    `json:"added"`
+	Removed      int    `json:"removed"`
+	// AvgTimeToReadySeconds is the average time from creation to Ready of the added nodes
+	AvgTimeToReadySeconds *float64 `json:"avgTimeToReadySeconds,omitempty"`
+}
+
+// Event is a Kubernetes event about a managed object
+type Event struct {
+	Time           time.Time `json:"time"`
+	Type           string    `json:"type"`
+	Reason         string    `json:"reason"`
+	Message        string    `json:"message"`
+	Count          int32     `json:"count,omitempty"`
+	InvolvedObject Resource  `json:"involvedObject"`
+}
+
+// Summarize fills in the node summary and, unless the run timed out, the time-to-capacity
+func (r *Report) Summarize() {
+	r.NodeSummary = SummarizeNodes(r.StartTime, r.Nodes)
+	r.TimeToCapacitySeconds = nil
+	if r.TimedOut {
+		return
+	}
+	var lastReady *time.Time
+	for _, pod := range r.Pods {
+		if pod.Ready == nil || pod.Ready.Before(r.StartTime.Truncate(time.Second)) {
+			continue
+		}
+		if lastReady == nil || pod.Ready.After(*lastReady) {
+			lastReady = pod.Ready
+		}
+	}
+	if lastReady != nil {
+		r.TimeToCapacitySeconds = lo.ToPtr(lastReady.Sub(r.StartTime).Seconds())
+	}
+}
+
+// SummarizeNodes groups nodes by instance type, zone and capacity type, nodes created before start only count as removed
+func SummarizeNodes(start time.Time, nodes []NodeTimeline) []NodeSummary {
+	summaries := map[NodeSummary]*NodeSummary{}
+	readyDurations := map[NodeSummary][]time.Duration{}
+	for _, node := range nodes {
+		key := NodeSummary{InstanceType: node.InstanceType, Zone: node.Zone, CapacityType: node.CapacityType}
+		if _, ok := summaries[key]; !ok {
+			summary := key
+			summaries[key] = &summary
+		}
+		if !node.Created.Before(start.Truncate(time.Second)) {
+			summaries[key].Added++
+			if node.Ready != nil {
+				readyDurations[key] = append(readyDurations[key], node.Ready.Sub(node.Created))
+			}
+		}
+		if node.Deleted != nil {
+			summaries[key].Removed++
+		}
+	}
+	for key, durations := range readyDurations {
+		summaries[key].AvgTimeToReadySeconds = lo.ToPtr((lo.Sum(durations) / time.Duration(len(durations))).Seconds())
+	}
+	result := lo.Map(lo.Values(summaries), func(summary *NodeSummary, _ int) NodeSummary { return *summary })
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if a.InstanceType != b.InstanceType {
+			return a.InstanceType < b.InstanceType
+		}
+		if a.Zone != b.Zone {
+			return a.Zone < b.Zone
+		}
+		return a.CapacityType < b.CapacityType
+	})
+	return result
+}
+
+// ResourcesOf describes objects returned by the API server
+func ResourcesOf(objects ...runtime.Object) []Resource {
+	var resources []Resource
+	for _, obj := range objects {
+		accessor, err := meta.Accessor(obj)
+		if err != nil {
+			continue
+		}
+		resources = append(resources, Resource{
+			Kind:      obj.GetObjectKind().GroupVersionKind().Kind,
+			Namespace: accessor.GetNamespace(),
+			Name:      accessor.GetName(),
+			UID:       accessor.GetUID(),
+		})
+	}
+	return resources
+}
+
+// Write writes the report as indented JSON to path
+func (r Report) Write(path string) error {
+	out, err := json.MarshalIndent(r, "", "    ")
+	if err != nil {
+		return err
+	}
+	//nolint:gosec
+	return os.WriteFile(path, out, 0o644)
+}
@@ -0,0 +1,106 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package report
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+)
+
+func TestSummarizeNodes(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 500_000_000, time.UTC)
+	at := func(seconds int) time.Time {
+		return start.Truncate(time.Second).Add(time.Duration(seconds) * time.Second)
+	}
+	for _, tc := range []struct {
+		name  string
+		nodes []NodeTimeline
+		want  []NodeSummary
+	}{
+		{name: "no nodes", want: []NodeSummary{}},
+		{
+			name: "added nodes average their time to ready",
+			nodes: []NodeTimeline{
+				{Name: "a", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Created: at(10), Ready: lo.ToPtr(at(40))},
+				{Name: "b", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Created: at(10), Ready: lo.ToPtr(at(60))},
+				{Name: "c", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Created: at(20)},
+			},
+			want: []NodeSummary{
+				{InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Added: 3, AvgTimeToReadySeconds: lo.ToPtr(40.0)},
+			},
+		},
+		{
+			name: "nodes created before the start only count as removed",
+			nodes: []NodeTimeline{
+				{Name: "old", InstanceType: "m5.large", Zone: "us-west-2a", Created: at(-3600), Ready: lo.ToPtr(at(-3500)), Deleted: lo.ToPtr(at(30))},
+				{Name: "kept", InstanceType: "m5.large", Zone: "us-west-2a", Created: at(-3600)},
+			},
+			want: []NodeSummary{{InstanceType: "m5.large", Zone: "us-west-2a", Removed: 1}},
+		},
+		{
+			name: "nodes created in the start second are added",
+			nodes: []NodeTimeline{
+				{Name: "a", InstanceType: "c6g.xlarge", Zone: "us-west-2b", CapacityType: "on-demand", Created: at(0), Deleted: lo.ToPtr(at(120))},
+			},
+			want: []NodeSummary{{InstanceType: "c6g.xlarge", Zone: "us-west-2b", CapacityType: "on-demand", Added: 1, Removed: 1}},
+		},
+		{
+			name: "sorted by instance type, zone and capacity type",
+			nodes: []NodeTimeline{
+				{Name: "a", InstanceType: "m5.large", Zone: "us-west-2b", CapacityType: "spot", Created: at(1)},
+				{Name: "b", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Created: at(1)},
+				{Name: "c", InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "on-demand", Created: at(1)},
+				{Name: "d", InstanceType: "c5.large", Zone: "us-west-2c", CapacityType: "spot", Created: at(1)},
+			},
+			want: []NodeSummary{
+				{InstanceType: "c5.large", Zone: "us-west-2c", CapacityType: "spot", Added: 1},
+				{InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "on-demand", Added: 1},
+				{InstanceType: "m5.large", Zone: "us-west-2a", CapacityType: "spot", Added: 1},
+				{InstanceType: "m5.large", Zone: "us-west-2b", CapacityType: "spot", Added: 1},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := SummarizeNodes(start, tc.nodes); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("SummarizeNodes() = %+v, want %+v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	start := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	report := Report{
+		StartTime: start,
+		Pods: []PodTimeline{
+			{Name: "before", Ready: lo.ToPtr(start.Add(-time.Minute))},
+			{Name: "a", Ready: lo.ToPtr(start.Add(30 * time.Second))},
+			{Name: "b", Ready: lo.ToPtr(start.Add(90 * time.Second))},
+			{Name: "pending"},
+		},
+	}
+	report.Summarize()
+	if report.TimeToCapacitySeconds == nil || *report.TimeToCapacitySeconds != 90 {
+		t.Errorf("TimeToCapacitySeconds = %v, want 90", lo.FromPtr(report.TimeToCapacitySeconds))
+	}
+	report.TimedOut = true
+	report.Summarize()
+	if report.TimeToCapacitySeconds != nil {
+		t.Errorf("TimeToCapacitySeconds = %v, want none when timed out", *report.TimeToCapacitySeconds)
+	}
+}
@@ -0,0 +1,273 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	metricsNamespace = "inflate"
+	labelNamespace   = "namespace"
+	labelName        = "name"
+	labelReason      = "reason"
+)
+
+// Watcher runs informers over inflate managed deployments and pods and exports prometheus metrics about them
+type Watcher struct {
+	clientset *kubernetes.Clientset
+	namespace string
+	registry  *prometheus.Registry
+
+	desiredReplicas   *prometheus.GaugeVec
+	readyReplicas     *prometheus.GaugeVec
+	pendingPods       *prometheus.GaugeVec
+	schedulingLatency *prometheus.HistogramVec
+	timeToReady       *prometheus.HistogramVec
+
+	// started is when the watcher started, pods created before it are not observed in the latency histograms
+	started   time.Time
+	mu        sync.Mutex
+	scheduled map[types.UID]struct{}
+	ready     map[types.UID]struct{}
+	// pending is the key each pending pod is counted under in pendingCounts
+	pending       map[types.UID]pendingKey
+	pendingCounts map[pendingKey]int
+}
+
+type pendingKey struct{ namespace, name, reason string }
+
+// New creates a Watcher over the inflates in namespace, or all namespaces when namespace is empty
+func New(clientset *kubernetes.Clientset, namespace string) *Watcher {
+	latencyBuckets := prometheus.ExponentialBuckets(0.5, 2, 12)
+	w := &Watcher{
+		clientset: clientset,
+		namespace: namespace,
+		registry:  prometheus.NewRegistry(),
+		desiredReplicas: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "desired_replicas",
+			Help:      "Desired replicas of the inflate deployment",
+		}, []string{labelNamespace, labelName}),
+		readyReplicas: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "ready_replicas",
+			Help:      "Ready replicas of the inflate deployment",
+		}, []string{labelNamespace, labelName}),
+		pendingPods: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "pending_pods",
+			Help:      "Pending inflate pods by the reason they are pending",
+		}, []string{labelNamespace, labelName, labelReason}),
+		schedulingLatency: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "pod_scheduling_latency_seconds",
+			Help:      "Time from pod creation until the pod was scheduled",
+			Buckets:   latencyBuckets,
+		}, []string{labelNamespace, labelName}),
+		timeToReady: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "pod_time_to_ready_seconds",
+			Help:      "Time from pod creation until the pod was ready",
+			Buckets:   latencyBuckets,
+		}, []string{labelNamespace, labelName}),
+		scheduled:     map[types.UID]struct{}{},
+		ready:         map[types.UID]struct{}{},
+		pending:       map[types.UID]pendingKey{},
+		pendingCounts: map[pendingKey]int{},
+	}
+	w.registry.MustRegister(w.desiredReplicas, w.readyReplicas, w.pendingPods, w.schedulingLatency, w.timeToReady)
+	return w
+}
+
+// Registry returns the registry the inflate metrics are registered with
+func (w *Watcher) Registry() *prometheus.Registry {
+	return w.registry
+}
+
+// Run starts the informers and blocks until ctx is done
+func (w *Watcher) Run(ctx context.Context) error {
+	// creation timestamps have a resolution of seconds
+	w.started = time.Now().Truncate(time.Second)
+	factory := informers.NewSharedInformerFactoryWithOptions(w.clientset, 0,
+		informers.WithNamespace(w.namespace),
+		informers.WithTweakListOptions(func(opts *metav1.ListOptions) {
+			opts.LabelSelector = "managed-by=inflate"
+		}),
+	)
+	deploymentInformer := factory.Apps().V1().Deployments().Informer()
+	if _, err := deploymentInformer.AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { w.updateDeployment(obj) },
+		UpdateFunc: func(_, obj any) { w.updateDeployment(obj) },
+		DeleteFunc: func(obj any) { w.deleteDeployment(obj) },
+	}); err != nil {
+		return err
+	}
+	if _, err := factory.Core().V1().Pods().Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj any) { w.updatePod(obj) },
+		UpdateFunc: func(_, obj any) { w.updatePod(obj) },
+		DeleteFunc: func(obj any) { w.deletePod(obj) },
+	}); err != nil {
+		return err
+	}
+	factory.Start(ctx.Done())
+	for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer for %v", informerType)
+		}
+	}
+	<-ctx.Done()
+	factory.Shutdown()
+	return nil
+}
+
+func (w *Watcher) updateDeployment(obj any) {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	w.desiredReplicas.WithLabelValues(deployment.Namespace, deployment.Name).Set(float64(desired))
+	w.readyReplicas.WithLabelValues(deployment.Namespace, deployment.Name).Set(float64(deployment.Status.ReadyReplicas))
+}
+
+func (w *Watcher) deleteDeployment(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return
+	}
+	w.desiredReplicas.DeleteLabelValues(deployment.Namespace, deployment.Name)
+	w.readyReplicas.DeleteLabelValues(deployment.Namespace, deployment.Name)
+}
+
+func (w *Watcher) updatePod(obj any) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	name := pod.Labels["app"]
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if pod.Status.Phase == corev1.PodPending && pod.DeletionTimestamp == nil {
+		w.setPending(pod.UID, &pendingKey{namespace: pod.Namespace, name: name, reason: PendingReason(pod)})
+	} else {
+		w.setPending(pod.UID, nil)
+	}
+	if pod.CreationTimestamp.Time.Before(w.started) {
+		return
+	}
+	if condition := podCondition(pod, corev1.PodScheduled); condition != nil && condition.Status == corev1.ConditionTrue {
+		if _, observed := w.scheduled[pod.UID]; !observed {
+			w.scheduled[pod.UID] = struct{}{}
+			w.schedulingLatency.WithLabelValues(pod.Namespace, name).Observe(since(pod, condition.LastTransitionTime.Time))
+		}
+	}
+	if condition := podCondition(pod, corev1.PodReady); condition != nil && condition.Status == corev1.ConditionTrue {
+		if _, observed := w.ready[pod.UID]; !observed {
+			w.ready[pod.UID] = struct{}{}
+			w.timeToReady.WithLabelValues(pod.Namespace, name).Observe(since(pod, condition.LastTransitionTime.Time))
+		}
+	}
+}
+
+func (w *Watcher) deletePod(obj any) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return
+	}
+	w.mu.Lock()
+	delete(w.scheduled, pod.UID)
+	delete(w.ready, pod.UID)
+	w.setPending(pod.UID, nil)
+	w.mu.Unlock()
+}
+
+// setPending moves a pod's count to key, or stops counting it when key is nil, so that reasons which no longer apply are dropped.
+// It must be called with mu held.
+func (w *Watcher) setPending(uid types.UID, key *pendingKey) {
+	if previous, ok := w.pending[uid]; ok {
+		if key != nil && previous == *key {
+			return
+		}
+		delete(w.pending, uid)
+		w.addPending(previous, -1)
+	}
+	if key != nil {
+		w.pending[uid] = *key
+		w.addPending(*key, 1)
+	}
+}
+
+func (w *Watcher) addPending(key pendingKey, delta int) {
+	w.pendingCounts[key] += delta
+	if w.pendingCounts[key] <= 0 {
+		delete(w.pendingCounts, key)
+		w.pendingPods.DeleteLabelValues(key.namespace, key.name, key.reason)
+		return
+	}
+	w.pendingPods.WithLabelValues(key.namespace, key.name, key.reason).Set(float64(w.pendingCounts[key]))
+}
+
+// PendingReason explains why a pending pod is not running yet,
+// e.g. Unschedulable from the scheduler or ImagePullBackOff from the kubelet
+func PendingReason(pod *corev1.Pod) string {
+	if condition := podCondition(pod, corev1.PodScheduled); condition == nil || condition.Status != corev1.ConditionTrue {
+		if condition != nil && condition.Reason != "" {
+			return condition.Reason
+		}
+		return "Unscheduled"
+	}
+	for _, status := range append(pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses...) {
+		if status.State.Waiting != nil && status.State.Waiting.Reason != "" {
+			return status.State.Waiting.Reason
+		}
+	}
+	return "Unknown"
+}
+
+func podCondition(pod *corev1.Pod, conditionType corev1.PodConditionType) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == conditionType {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// since returns the seconds between a pod's creation and t
+func since(pod *corev1.Pod, t time.Time) float64 {
+	return t.Sub(pod.CreationTimestamp.Time).Seconds()
+}
@@ -0,0 +1,80 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package watcher
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/cache"
+)
+
+func pod(uid string, created time.Time, phase corev1.PodPhase, conditions ...corev1.PodCondition) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              uid,
+			Namespace:         "inflate",
+			UID:               types.UID(uid),
+			Labels:            map[string]string{"app": "inflate"},
+			CreationTimestamp: metav1.NewTime(created),
+		},
+		Status: corev1.PodStatus{Phase: phase, Conditions: conditions},
+	}
+}
+
+func TestPendingPods(t *testing.T) {
+	w := New(nil, "")
+	now := time.Now()
+	unschedulable := corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionFalse, Reason: corev1.PodReasonUnschedulable}
+	scheduled := corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(now)}
+
+	w.updatePod(pod("a", now, corev1.PodPending, unschedulable))
+	w.updatePod(pod("b", now, corev1.PodPending, unschedulable))
+	w.updatePod(pod("b", now, corev1.PodPending, unschedulable))
+	w.updatePod(pod("c", now, corev1.PodPending))
+	if got := testutil.ToFloat64(w.pendingPods.WithLabelValues("inflate", "inflate", corev1.PodReasonUnschedulable)); got != 2 {
+		t.Errorf("unschedulable pending pods = %v, want 2", got)
+	}
+
+	w.updatePod(pod("a", now, corev1.PodRunning, scheduled))
+	w.deletePod(pod("b", now, corev1.PodPending, unschedulable))
+	if got := testutil.ToFloat64(w.pendingPods.WithLabelValues("inflate", "inflate", "Unscheduled")); got != 1 {
+		t.Errorf("unscheduled pending pods = %v, want 1", got)
+	}
+	w.deletePod(cache.DeletedFinalStateUnknown{Obj: pod("c", now, corev1.PodPending)})
+	if got := testutil.CollectAndCount(w.pendingPods); got != 0 {
+		t.Errorf("pending pod series = %d, want none", got)
+	}
+}
+
+func TestLatencySkipsPodsCreatedBeforeStart(t *testing.T) {
+	w := New(nil, "")
+	w.started = time.Now().Truncate(time.Second)
+	created := w.started.Add(-time.Hour)
+	scheduled := corev1.PodCondition{Type: corev1.PodScheduled, Status: corev1.ConditionTrue, LastTransitionTime: metav1.NewTime(w.started)}
+
+	w.updatePod(pod("old", created, corev1.PodRunning, scheduled))
+	if got := testutil.CollectAndCount(w.schedulingLatency); got != 0 {
+		t.Errorf("scheduling latency series = %d, want none for a pod created before the watcher started", got)
+	}
+	w.updatePod(pod("new", w.started, corev1.PodRunning, scheduled))
+	if got := testutil.CollectAndCount(w.schedulingLatency); got != 1 {
+		t.Errorf("scheduling latency series = %d, want 1", got)
+	}
+}